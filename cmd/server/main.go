@@ -4,10 +4,13 @@ import (
 	"encoding/hex"
 	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	"FileTree-API/internal/handler"
 	"FileTree-API/internal/middleware"
 	"FileTree-API/internal/security"
+	"FileTree-API/internal/service"
 	"FileTree-API/internal/utils"
 
 	"github.com/gorilla/mux"
@@ -33,6 +36,38 @@ func main() {
 
 	// Pass the key and salt to the security package
 	security.SetKeyAndSalt(key, salt)
+	// Configure the validity window of signed links
+	security.SetClockSkew(utils.GetEnvDuration("FILETREE_CLOCK_SKEW", 30*time.Second))
+	security.SetRequireExpiry(utils.GetEnvBool("FILETREE_REQUIRE_EXPIRY", false))
+
+	// Restrict the served paths to the allowed roots
+	roots, err := security.ParseRoots(os.Getenv("FILETREE_ROOTS"))
+	if err != nil {
+		utils.OutputMessage(nil, utils.FatalOutput, 0, "Failed to parse FILETREE_ROOTS: %v", err)
+	}
+	if len(roots) == 0 {
+		utils.OutputMessage(nil, utils.LogOutput, 0, "FILETREE_ROOTS is not set, every path on the server can be listed\n")
+	}
+	security.SetAllowedRoots(roots)
+	security.SetForbidHidden(strings.Split(os.Getenv("FILETREE_FORBID_HIDDEN"), ","))
+
+	// Limit how long a single file tree walk may take
+	service.SetMaxWalkDuration(utils.GetEnvDuration("FILETREE_MAX_WALK_DURATION", 0))
+	// Cap the resources a single walk may use
+	service.SetLimits(service.Limits{
+		MaxNodes: int64(utils.GetEnvInt("FILETREE_MAX_NODES", 0)),
+		MaxDepth: utils.GetEnvInt("FILETREE_MAX_DEPTH", 0),
+		MaxBytes: int64(utils.GetEnvInt("FILETREE_MAX_BYTES", 0)),
+	})
+
+	// Configure the file checksums
+	service.SetHashWorkers(utils.GetEnvInt("FILETREE_HASH_WORKERS", 0))
+	service.SetHashCacheSize(utils.GetEnvInt("FILETREE_HASH_CACHE_SIZE", 0))
+	if path := os.Getenv("FILETREE_HASH_CACHE"); path != "" {
+		if err := service.SetHashCache(path); err != nil {
+			utils.OutputMessage(nil, utils.FatalOutput, 0, "Failed to load FILETREE_HASH_CACHE: %v", err)
+		}
+	}
 
 	// Create a new Gorilla Mux HTTP router
 	r := mux.NewRouter()
@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"bytes"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/gorilla/websocket"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// codec encodes whole responses and WebSocket messages in one of the output formats.
+// Every format uses the json struct tags, so all of them share the same schema.
+type codec struct {
+	marshal     func(v interface{}) ([]byte, error)
+	messageType int // Type of the WebSocket messages
+}
+
+// codecs are the output formats that encode the response as a single document
+var codecs = map[string]codec{
+	FormatJSON:    {marshal: json.Marshal, messageType: websocket.TextMessage},
+	FormatMsgPack: {marshal: marshalMsgPack, messageType: websocket.BinaryMessage},
+	FormatCBOR:    {marshal: cbor.Marshal, messageType: websocket.BinaryMessage}, // Falls back to the json tags
+}
+
+// Encodes the value as MessagePack with the json field names and omitempty rules
+func marshalMsgPack(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	enc.UseCompactInts(true)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"FileTree-API/internal/service"
+	"FileTree-API/pkg/api"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Decoders of the codecs into generic values
+var decoders = map[string]func(data []byte, v interface{}) error{
+	FormatJSON:    json.Unmarshal,
+	FormatMsgPack: unmarshalMsgPack,
+	FormatCBOR:    cbor.Unmarshal,
+}
+
+// Decodes MessagePack, allowing the integer keys of the sparse compact columns
+func unmarshalMsgPack(data []byte, v interface{}) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetMapDecoder(func(d *msgpack.Decoder) (interface{}, error) {
+		return d.DecodeUntypedMap()
+	})
+
+	return dec.Decode(v)
+}
+
+// Brings the generic values of every codec to the form JSON decodes to: maps with string keys,
+// float64 numbers and Base64 text for bytes
+func normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			out[key] = normalize(value)
+		}
+		return out
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			out[fmt.Sprint(key)] = normalize(value)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, value := range v {
+			out[i] = normalize(value)
+		}
+		return out
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v)
+	case int8:
+		return float64(v)
+	case int16:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case int:
+		return float64(v)
+	case uint8:
+		return float64(v)
+	case uint16:
+		return float64(v)
+	case uint32:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case float32:
+		return float64(v)
+	}
+
+	return v
+}
+
+// Encodes the value with every codec and checks that all of them decode to the JSON output
+func checkParity(t *testing.T, value interface{}) {
+	t.Helper()
+	var expected interface{}
+	for _, format := range []string{FormatJSON, FormatMsgPack, FormatCBOR} {
+		data, err := codecs[format].marshal(value)
+		if err != nil {
+			t.Fatalf("%s: encoding failed: %v", format, err)
+		}
+		var decoded interface{}
+		if err := decoders[format](data, &decoded); err != nil {
+			t.Fatalf("%s: decoding failed: %v", format, err)
+		}
+		decoded = normalize(decoded)
+
+		if format == FormatJSON {
+			expected = decoded
+			continue
+		}
+		if !reflect.DeepEqual(decoded, expected) {
+			t.Errorf("%s output differs from JSON:\n got %v\nwant %v", format, decoded, expected)
+		}
+	}
+}
+
+// Creates the files under a temporary root, directories are created as needed
+func makeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return root
+}
+
+func TestCodecParity(t *testing.T) {
+	// Nested directories, several file types and a link
+	root := makeTree(t, map[string]string{
+		"a.txt":           "hello",
+		"photos/b.jpg":    "\xff\xd8\xff\xe0 not really a jpeg",
+		"photos/2024/c":   "",
+		"docs/d.md":       "# title",
+		"docs/empty/.kep": "",
+	})
+	if err := os.Symlink("a.txt", filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		opts service.Options
+	}{
+		{name: "tree", opts: service.Options{Mode: service.ModeTree, Hash: service.HashXXHash, Mime: true}},
+		{name: "org", opts: service.Options{Mode: service.ModeOrganize, PathMode: service.PathModeRelative}},
+		{name: "compact", opts: service.Options{Mode: service.ModeTree, Compact: true, Hash: service.HashSHA256}},
+		{name: "full metadata", opts: service.Options{Metadata: service.MetadataFull, MaxDepth: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := service.GenerateFileTree(context.Background(), root, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkParity(t, api.NewSuccessResponse(result))
+		})
+	}
+}
+
+func TestCodecParityMessages(t *testing.T) {
+	node := service.FileNode{Name: "a.txt", Path: "a.txt", Size: 5, LastModified: time.Now().Unix(), Parent: "."}
+
+	checkParity(t, wrapChunkData{Index: 0, TotalChunks: 2, Progress: 50, Data: []byte("\x00\x01binary\xff")})
+	checkParity(t, nodesMessage{Type: "nodes", Nodes: []service.FileNode{node, {Name: ".", Path: ".", IsDir: true}}})
+	checkParity(t, matchMessage{Type: "match", Match: &service.SearchMatch{Node: &node}})
+	checkParity(t, api.NewErrorResponse("path not found"))
+}
@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"FileTree-API/internal/payload"
+	"FileTree-API/internal/service"
+	"FileTree-API/internal/utils"
+)
+
+// Output formats of the HTTP responses
+const (
+	FormatJSON    = "json"
+	FormatNDJSON  = "ndjson" // One node per line, see the stream payload option
+	FormatCSV     = "csv"
+	FormatTSV     = "tsv"
+	FormatMsgPack = "msgpack"
+	FormatCBOR    = "cbor"
+)
+
+// contentTypes maps the output formats to the Content-Type of the response
+var contentTypes = map[string]string{
+	FormatJSON:    "application/json",
+	FormatNDJSON:  "application/x-ndjson",
+	FormatCSV:     "text/csv; charset=utf-8",
+	FormatTSV:     "text/tab-separated-values; charset=utf-8",
+	FormatMsgPack: "application/msgpack",
+	FormatCBOR:    "application/cbor",
+}
+
+// acceptedTypes maps the media types of the Accept header to the output formats
+var acceptedTypes = map[string]string{
+	"application/json":          FormatJSON,
+	"application/x-ndjson":      FormatNDJSON,
+	"application/jsonl":         FormatNDJSON,
+	"text/csv":                  FormatCSV,
+	"text/tab-separated-values": FormatTSV,
+	"application/msgpack":       FormatMsgPack,
+	"application/x-msgpack":     FormatMsgPack,
+	"application/vnd.msgpack":   FormatMsgPack,
+	"application/cbor":          FormatCBOR,
+}
+
+// Subprotocols of the WebSocket, choosing how its messages are encoded. JSON is used when the
+// client asks for none of them.
+var subprotocols = []string{FormatJSON, FormatMsgPack, FormatCBOR}
+
+// Picks the output format from the payload, or from the Accept header when the payload has none,
+// and makes sure the rest of the payload can be written in it.
+func negotiateFormat(r *http.Request, p *payload.Payload) error {
+	// WebSocket messages are encoded according to the subprotocol, the payload may only ask for
+	// JSON or for NDJSON, which is sent like a stream
+	if utils.IsWebSocket(r) {
+		switch p.Format {
+		case "":
+			p.Format = FormatJSON
+		case FormatJSON, FormatNDJSON:
+		default:
+			return fmt.Errorf("%w: %q over the WebSocket, choose the encoding with the subprotocol", ErrUnsupportedFormat, p.Format)
+		}
+	}
+	if p.Format == "" {
+		p.Format = acceptedFormat(r.Header.Get("Accept"))
+	}
+	if _, ok := contentTypes[p.Format]; !ok {
+		return fmt.Errorf("%w: %q", ErrUnsupportedFormat, p.Format)
+	}
+
+	// Only JSON documents and lines are written as the nodes are found
+	if p.Stream && p.Format != FormatJSON && p.Format != FormatNDJSON {
+		return fmt.Errorf("%w: %s cannot be streamed", ErrUnsupportedFormat, p.Format)
+	}
+
+	// Tables are built from the flattened tree once the walk is done
+	if p.Format == FormatCSV || p.Format == FormatTSV {
+		if p.Compact {
+			return fmt.Errorf("%w: compact trees cannot be written as %s", ErrUnsupportedFormat, p.Format)
+		}
+		if p.Mode != "" && p.Mode != service.ModeTree && p.Mode != service.ModeOrganize {
+			return fmt.Errorf("%w: the %q mode cannot be written as %s", ErrUnsupportedFormat, p.Mode, p.Format)
+		}
+		return checkColumns(p.Columns)
+	}
+
+	return nil
+}
+
+// Returns the format of the known media type with the highest quality in the Accept header, the
+// first one listed among equals. Types with a quality of 0 are refused, and JSON is used when no
+// known type is accepted.
+func acceptedFormat(accept string) string {
+	format, best := FormatJSON, 0.0
+	for _, accepted := range strings.Split(accept, ",") {
+		mediaType, params, _ := strings.Cut(accepted, ";")
+		candidate, ok := acceptedTypes[strings.ToLower(strings.TrimSpace(mediaType))]
+		if !ok {
+			continue
+		}
+		quality := 1.0
+		for _, param := range strings.Split(params, ";") {
+			name, value, _ := strings.Cut(param, "=")
+			if strings.EqualFold(strings.TrimSpace(name), "q") {
+				if q, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && q >= 0 && q <= 1 {
+					quality = q
+				}
+			}
+		}
+		if quality > best {
+			format, best = candidate, quality
+		}
+	}
+
+	return format
+}
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"FileTree-API/internal/payload"
+)
+
+func TestAcceptedFormat(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{accept: "", want: FormatJSON},
+		{accept: "*/*", want: FormatJSON},
+		{accept: "text/csv", want: FormatCSV},
+		{accept: "text/html, text/csv", want: FormatCSV},
+		{accept: "text/csv, application/json", want: FormatCSV},
+		{accept: "text/csv;q=0, application/json", want: FormatJSON},
+		{accept: "text/csv;q=0", want: FormatJSON},
+		{accept: "text/csv;q=0.5, application/cbor;q=0.8", want: FormatCBOR},
+		{accept: "application/cbor;q=0.8, text/csv;charset=utf-8;q=0.9", want: FormatCSV},
+		{accept: "Application/MsgPack ; Q=0.7, application/x-ndjson;q=0.7", want: FormatMsgPack},
+		{accept: "application/x-ndjson;q=invalid, text/csv;q=0.9", want: FormatNDJSON},
+	}
+	for _, tt := range tests {
+		if got := acceptedFormat(tt.accept); got != tt.want {
+			t.Errorf("acceptedFormat(%q) = %q, want %q", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestNegotiateFormatWebSocket(t *testing.T) {
+	tests := []struct {
+		format   string
+		expected string // Empty when the format is refused
+	}{
+		{format: "", expected: FormatJSON},
+		{format: FormatJSON, expected: FormatJSON},
+		{format: FormatNDJSON, expected: FormatNDJSON},
+		{format: FormatCSV},
+		{format: FormatTSV},
+		{format: FormatMsgPack},
+		{format: FormatCBOR},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Connection", "Upgrade")
+		r.Header.Set("Upgrade", "websocket")
+		r.Header.Set("Accept", "text/csv") // Ignored over the WebSocket
+		p := &payload.Payload{Format: tt.format}
+
+		err := negotiateFormat(r, p)
+		if tt.expected == "" {
+			if !errors.Is(err, ErrUnsupportedFormat) {
+				t.Errorf("format %q: error = %v, want %v", tt.format, err, ErrUnsupportedFormat)
+			}
+			continue
+		}
+		if err != nil || p.Format != tt.expected {
+			t.Errorf("format %q: got %q, %v, want %q", tt.format, p.Format, err, tt.expected)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"net/http"
 
+	"FileTree-API/internal/payload"
 	"FileTree-API/internal/security"
 	"FileTree-API/internal/service"
 	"FileTree-API/internal/utils"
@@ -18,7 +19,10 @@ var (
 	ErrFailedToDecrypt         = errors.New("failed to decrypt the path")
 	ErrInvalidSignature        = errors.New("invalid signature")
 	ErrInvalidSignatureFormat  = errors.New("invalid signature format")
+	ErrInvalidTimestamp        = errors.New("invalid timestamp")
 	ErrErrorGeneratingFileTree = errors.New("error generating file tree")
+	ErrPathNotFound            = errors.New("path not found")
+	ErrUnsupportedFormat       = errors.New("unsupported output format")
 )
 
 func DefaultHandler(w http.ResponseWriter, r *http.Request) {
@@ -38,8 +42,15 @@ func UnifiedHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// Hooks receive the results of a walk as soon as they are found, any of them may be nil
+type Hooks struct {
+	OnStart func(*payload.Payload) // Called with the payload right before the walk starts
+	OnMatch func(*service.SearchMatch)
+	OnNode  func(*service.FileNode) // Only used when the payload asks for a stream
+}
+
 // Processes the encrypted path, decrypts it, and generates the file tree.
-func ProcessEncryptedPath(r *http.Request) (interface{}, error) {
+func ProcessEncryptedPath(r *http.Request, hooks Hooks) (interface{}, error) {
 	vars := mux.Vars(r)
 
 	// Get the signature and encrypted parameters from the route or query parameters
@@ -56,10 +67,72 @@ func ProcessEncryptedPath(r *http.Request) (interface{}, error) {
 		return nil, ErrFailedToDecrypt
 	}
 
+	// Parse the path and options carried in the payload
+	p, err := payload.Parse(decryptedPath)
+	if err != nil {
+		api.BadRequestError(err.Error())
+		return nil, err
+	}
+	if err := security.CheckExpiry(p.Expires); err != nil {
+		api.NewAPIError(http.StatusGone, "Gone", err.Error())
+		return nil, err
+	}
+
+	// Make sure the requested path lies inside an allowed root
+	resolved, err := security.ResolvePath(p.Path)
+	if err != nil {
+		if errors.Is(err, security.ErrPathNotAllowed) {
+			api.NewAPIError(http.StatusForbidden, "Forbidden", err.Error())
+			return nil, err
+		}
+		api.NotFoundError(ErrPathNotFound.Error())
+		return nil, ErrPathNotFound
+	}
+	if err := resolved.CheckHidden(p.IncludeHidden); err != nil {
+		api.NewAPIError(http.StatusForbidden, "Forbidden", err.Error())
+		return nil, err
+	}
+	if resolved.Root != nil {
+		p.Scope.Base = resolved.Root.Path
+	}
+	p.Scope.Alias = resolved.Alias
+	// Folders requested relative to a root keep hiding the real server path by default
+	if p.PathMode == "" && resolved.Named {
+		p.PathMode = service.PathModeAlias
+	}
+
+	// Choose the output format, NDJSON lines are written as soon as the nodes are found
+	if err := negotiateFormat(r, p); err != nil {
+		api.BadRequestError(err.Error())
+		return nil, err
+	}
+	if p.Format == FormatNDJSON {
+		p.Stream = true
+	}
+	if hooks.OnStart != nil {
+		hooks.OnStart(p)
+	}
+
+	p.OnMatch = hooks.OnMatch
+	if p.Stream && p.Mode != service.ModeSearch {
+		p.OnNode = hooks.OnNode
+	}
+
 	// Generate the file tree using the decrypted path
-	realPath, organize := utils.CheckOrganize(decryptedPath)
-	fileTreeResult, err := service.GenerateFileTree(realPath, organize)
+	fileTreeResult, err := service.GenerateFileTree(r.Context(), resolved.Path, p.Options)
 	if err != nil {
+		if errors.Is(err, service.ErrWalkTimeout) || errors.Is(err, service.ErrWalkCanceled) {
+			api.NewAPIError(http.StatusServiceUnavailable, "ServiceUnavailable", err.Error())
+			return nil, err
+		}
+		if errors.Is(err, service.ErrUnreadableEntries) {
+			api.InternalServerError(err.Error())
+			return nil, err
+		}
+		if errors.Is(err, service.ErrInvalidOptions) {
+			api.BadRequestError(err.Error())
+			return nil, err
+		}
 		api.InternalServerError(ErrErrorGeneratingFileTree.Error())
 		return nil, ErrErrorGeneratingFileTree
 	}
@@ -69,14 +142,42 @@ func ProcessEncryptedPath(r *http.Request) (interface{}, error) {
 
 // Maps specific error types to HTTP status codes
 func DetermineHTTPStatusCode(err error) int {
-	switch err {
-	case ErrMissingEncryptedParam:
+	switch {
+	case errors.Is(err, ErrMissingEncryptedParam):
 		// Missing parameter
 		return http.StatusUnauthorized
-	case ErrFailedToDecrypt:
+	case errors.Is(err, ErrFailedToDecrypt):
 		// Failed to decrypt could imply a wrong input
 		return http.StatusUnauthorized
-	case ErrErrorGeneratingFileTree:
+	case errors.Is(err, payload.ErrInvalidPayload),
+		errors.Is(err, payload.ErrUnsupportedVersion),
+		errors.Is(err, payload.ErrMissingPath),
+		errors.Is(err, service.ErrInvalidOptions),
+		errors.Is(err, ErrUnsupportedFormat):
+		// The payload decrypted fine but its content cannot be used
+		return http.StatusBadRequest
+	case errors.Is(err, ErrInvalidTimestamp):
+		// Timestamps that are not Unix seconds
+		return http.StatusBadRequest
+	case errors.Is(err, security.ErrLinkExpired):
+		// The link was valid once but must not be used anymore
+		return http.StatusGone
+	case errors.Is(err, security.ErrLinkNotYetValid),
+		errors.Is(err, security.ErrMissingExpiry):
+		// The link is not valid at this time
+		return http.StatusForbidden
+	case errors.Is(err, security.ErrPathNotAllowed),
+		errors.Is(err, security.ErrHiddenForbidden):
+		// The path exists but must not be served
+		return http.StatusForbidden
+	case errors.Is(err, ErrPathNotFound):
+		// The path cannot be resolved on the server
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrWalkTimeout),
+		errors.Is(err, service.ErrWalkCanceled):
+		// The walk was aborted before completion
+		return http.StatusServiceUnavailable
+	case errors.Is(err, ErrErrorGeneratingFileTree):
 		// Error generating file tree implies internal server problems
 		return http.StatusInternalServerError
 	default:
@@ -3,6 +3,8 @@ package handler
 import (
 	"net/http"
 
+	"FileTree-API/internal/payload"
+	"FileTree-API/internal/service"
 	"FileTree-API/internal/utils"
 	"FileTree-API/pkg/api"
 
@@ -15,7 +17,22 @@ func HTTPMessage(w http.ResponseWriter, r *http.Request, message string) {
 
 func HTTPHandler(w http.ResponseWriter, r *http.Request) {
 	json := jsoniter.ConfigCompatibleWithStandardLibrary
-	fileTreeResult, err := ProcessEncryptedPath(r)
+	var p *payload.Payload
+	stream := &httpNodeStream{w: w}
+	fileTreeResult, err := ProcessEncryptedPath(r, Hooks{
+		OnStart: func(started *payload.Payload) {
+			p = started
+			stream.start(started)
+		},
+		OnMatch: stream.writeMatch,
+		OnNode:  stream.write,
+	})
+
+	// Streamed responses are already under way and only need to be completed
+	if stream.started() || err == nil && stream.streamed() {
+		stream.finish(fileTreeResult, err)
+		return
+	}
 
 	if err != nil {
 		errorMsg := err.Error()
@@ -25,6 +42,25 @@ func HTTPHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Tables are sent as they are, without the response envelope
+	if p.Format == FormatCSV || p.Format == FormatTSV {
+		writeTable(w, fileTreeResult.(*service.FileTreeResult), p)
+		return
+	}
+
+	// Binary encodings of the same response
+	if c, ok := codecs[p.Format]; ok && p.Format != FormatJSON {
+		data, err := c.marshal(api.NewSuccessResponse(fileTreeResult))
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.NewErrorResponse(err.Error()))
+			return
+		}
+		w.Header().Set("Content-Type", contentTypes[p.Format])
+		w.Write(data)
+		return
+	}
+
 	// Return the file tree
 	response := api.NewSuccessResponse(fileTreeResult)
 	w.Header().Set("Content-Type", "application/json")
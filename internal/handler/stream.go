@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"FileTree-API/internal/payload"
+	"FileTree-API/internal/service"
+
+	"github.com/gorilla/websocket"
+)
+
+// streamBatchSize is the number of streamed nodes sent at once, the first node is always sent right away
+const streamBatchSize = 256
+
+// httpNodeStream writes the streamed nodes or search matches of a walk to the response as they are
+// found, either as NDJSON lines or as an incrementally encoded JSON document. The walk may still fail
+// once the first items are sent, so success and message come last in the document:
+// {"data":{"nodes":[...],"summary":{...}},"success":true,"message":"success"}
+type httpNodeStream struct {
+	w      http.ResponseWriter
+	format string
+	field  string // Name of the streamed list in JSON documents, nodes or matches
+	stream bool
+	begun  bool
+	count  int
+}
+
+// Remembers the output format of the request
+func (s *httpNodeStream) start(p *payload.Payload) {
+	s.format = p.Format
+	s.stream = p.Stream
+	s.field = "nodes"
+	if p.Mode == service.ModeSearch {
+		s.field = "matches"
+	}
+}
+
+func (s *httpNodeStream) write(node *service.FileNode) {
+	s.writeItem(node)
+}
+
+// Writes a search match, only when the payload asks for a stream
+func (s *httpNodeStream) writeMatch(match *service.SearchMatch) {
+	if s.stream {
+		s.writeItem(match)
+	}
+}
+
+func (s *httpNodeStream) writeItem(item interface{}) {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return
+	}
+	s.begin()
+	if s.format == FormatNDJSON {
+		s.w.Write(append(data, '\n'))
+	} else {
+		if s.count > 0 {
+			s.w.Write([]byte(","))
+		}
+		s.w.Write(data)
+	}
+	s.count++
+
+	if s.count%streamBatchSize == 1 {
+		if flusher, ok := s.w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+	}
+}
+
+// Writes the headers, and opens the document unless the items are written as lines
+func (s *httpNodeStream) begin() {
+	if s.begun {
+		return
+	}
+	s.begun = true
+	s.w.Header().Set("Content-Type", contentTypes[s.format])
+	if s.format != FormatNDJSON {
+		s.w.Write([]byte(`{"data":{"` + s.field + `":[`))
+	}
+}
+
+// Reports whether the response was started, after which errors can only be written at its end
+func (s *httpNodeStream) started() bool {
+	return s.begun
+}
+
+// Reports whether the walk of the request is streamed, so its response is written by the stream
+// even when no item was found
+func (s *httpNodeStream) streamed() bool {
+	return s.stream
+}
+
+// summaryLine is the last line of NDJSON listings, telling it apart from the nodes by its type
+type summaryLine struct {
+	Type string `json:"type"`
+	*service.FileTreeResult
+}
+
+// Closes the document with the summary of the walk, or with the error that stopped it.
+// NDJSON listings end with a summary line, and the response is aborted on errors to let the
+// client know the listing is incomplete.
+func (s *httpNodeStream) finish(result interface{}, err error) {
+	s.begin()
+	if s.format == FormatNDJSON {
+		if err != nil {
+			panic(http.ErrAbortHandler)
+		}
+		if summary, ok := result.(*service.FileTreeResult); ok {
+			data, _ := json.Marshal(summaryLine{Type: "summary", FileTreeResult: summary})
+			s.w.Write(append(data, '\n'))
+		}
+		return
+	}
+	if err != nil {
+		message, _ := json.Marshal(err.Error())
+		s.w.Write([]byte(`]},"success":false,"message":`))
+		s.w.Write(message)
+		s.w.Write([]byte("}\n"))
+		return
+	}
+
+	summary, _ := json.Marshal(result)
+	s.w.Write([]byte(`],"summary":`))
+	s.w.Write(summary)
+	s.w.Write([]byte(`},"success":true,"message":"success"}` + "\n"))
+}
+
+// nodesMessage carries a batch of streamed nodes, sent before the chunked result
+type nodesMessage struct {
+	Type  string             `json:"type"`
+	Nodes []service.FileNode `json:"nodes"`
+}
+
+// wsNodeStream sends the streamed nodes of a walk over the WebSocket in batches
+type wsNodeStream struct {
+	conn   *websocket.Conn
+	codec  codec
+	cancel context.CancelFunc
+	nodes  []service.FileNode // Copies taken as soon as the nodes are found, the walk keeps changing them
+	count  int
+}
+
+func (s *wsNodeStream) write(node *service.FileNode) {
+	s.nodes = append(s.nodes, *node)
+	s.count++
+
+	if s.count%streamBatchSize == 1 {
+		s.flush()
+	}
+}
+
+// Sends the current batch as a {"type":"nodes","nodes":[...]} message
+func (s *wsNodeStream) flush() {
+	if len(s.nodes) == 0 {
+		return
+	}
+	message, err := s.codec.marshal(nodesMessage{Type: "nodes", Nodes: s.nodes})
+	s.nodes = s.nodes[:0]
+	if err != nil {
+		return
+	}
+
+	if err := s.conn.WriteMessage(s.codec.messageType, message); err != nil {
+		s.cancel()
+	}
+}
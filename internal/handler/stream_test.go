@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"bufio"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"FileTree-API/internal/payload"
+	"FileTree-API/internal/service"
+)
+
+func TestNDJSONSummaryLine(t *testing.T) {
+	rec := httptest.NewRecorder()
+	stream := &httpNodeStream{w: rec, format: FormatNDJSON}
+	stream.write(&service.FileNode{Name: "root", Path: "root", IsDir: true})
+	stream.write(&service.FileNode{Name: "a", Path: "root/a"})
+	stream.finish(&service.FileTreeResult{
+		Truncated:     true,
+		TruncatedDirs: []string{"root"},
+		Errors:        []service.EntryError{{Path: "root/b", Error: "permission denied"}},
+	}, nil)
+
+	var lines []map[string]interface{}
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		var line map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("invalid line %q: %v", scanner.Text(), err)
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 2 nodes and the summary", len(lines))
+	}
+	summary := lines[2]
+	if summary["type"] != "summary" || summary["truncated"] != true {
+		t.Errorf("last line is not a truncated summary: %v", summary)
+	}
+	if dirs, _ := summary["truncatedDirs"].([]interface{}); len(dirs) != 1 {
+		t.Errorf("truncatedDirs = %v, want [root]", summary["truncatedDirs"])
+	}
+	if errors, _ := summary["errors"].([]interface{}); len(errors) != 1 {
+		t.Errorf("errors = %v, want one error", summary["errors"])
+	}
+	for _, node := range lines[:2] {
+		if _, ok := node["type"]; ok {
+			t.Errorf("node line has a type: %v", node)
+		}
+	}
+}
+
+func TestStreamedMatches(t *testing.T) {
+	match := &service.SearchMatch{Node: &service.FileNode{Name: "a.jpg", Path: "root/a.jpg"}}
+	tests := []struct {
+		name   string
+		p      *payload.Payload
+		want   string
+		silent bool
+	}{
+		{name: "json", p: streamPayload(FormatJSON, true), want: `{"data":{"matches":[{"node":`},
+		{name: "ndjson", p: streamPayload(FormatNDJSON, true), want: `{"node":`},
+		{name: "not streamed", p: streamPayload(FormatJSON, false), silent: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			stream := &httpNodeStream{w: rec}
+			stream.start(tt.p)
+			stream.writeMatch(match)
+
+			if tt.silent {
+				if stream.started() || rec.Body.Len() > 0 {
+					t.Errorf("matches written without a stream: %q", rec.Body.String())
+				}
+				return
+			}
+			stream.finish(&service.FileTreeResult{}, nil)
+			if body := rec.Body.String(); !strings.HasPrefix(body, tt.want) {
+				t.Errorf("body %q does not start with %q", body, tt.want)
+			}
+			if got := rec.Header().Get("Content-Type"); got != contentTypes[tt.p.Format] {
+				t.Errorf("Content-Type = %q, want %q", got, contentTypes[tt.p.Format])
+			}
+		})
+	}
+}
+
+func TestStreamWithoutItems(t *testing.T) {
+	rec := httptest.NewRecorder()
+	stream := &httpNodeStream{w: rec}
+	stream.start(streamPayload(FormatJSON, true))
+	stream.finish(&service.FileTreeResult{}, nil)
+
+	var document map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &document); err != nil {
+		t.Fatalf("invalid document %q: %v", rec.Body.String(), err)
+	}
+	data, _ := document["data"].(map[string]interface{})
+	if matches, ok := data["matches"].([]interface{}); !ok || len(matches) != 0 {
+		t.Errorf("matches = %v, want an empty list", data["matches"])
+	}
+}
+
+// Returns the payload of a search written in the format
+func streamPayload(format string, stream bool) *payload.Payload {
+	p := &payload.Payload{Format: format}
+	p.Mode = service.ModeSearch
+	p.Stream = stream
+
+	return p
+}
@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"encoding/csv"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"FileTree-API/internal/payload"
+	"FileTree-API/internal/service"
+)
+
+// Columns of the CSV and TSV formats
+const (
+	ColumnPath  = "path"
+	ColumnName  = "name"
+	ColumnType  = "type" // dir, symlink or file
+	ColumnSize  = "size"
+	ColumnMtime = "mtime"
+	ColumnCtime = "ctime" // Birth time, empty when it is not known
+	ColumnHash  = "hash"  // Only filled in when the payload asks for a hash
+)
+
+// Headers summarizing what the table cannot hold, as tables only list the nodes
+const (
+	HeaderTruncated = "X-FileTree-Truncated" // "true" when a limit was reached and some directories are incomplete
+	HeaderErrors    = "X-FileTree-Errors"    // Number of entries that could not be read
+)
+
+// formulaPrefixes start cells that spreadsheets would evaluate as formulas
+const formulaPrefixes = "=+-@\t\r"
+
+// defaultColumns are written when the payload does not choose any
+var defaultColumns = []string{ColumnPath, ColumnName, ColumnType, ColumnSize, ColumnMtime}
+
+// Makes sure every requested column exists
+func checkColumns(columns []string) error {
+	for _, column := range columns {
+		switch column {
+		case ColumnPath, ColumnName, ColumnType, ColumnSize, ColumnMtime, ColumnCtime, ColumnHash:
+		default:
+			return fmt.Errorf("%w: unknown column %q", ErrUnsupportedFormat, column)
+		}
+	}
+
+	return nil
+}
+
+// Writes the flattened tree as a CSV or TSV attachment named after the requested folder,
+// with a header row followed by the directories and then the files
+func writeTable(w http.ResponseWriter, result *service.FileTreeResult, p *payload.Payload) {
+	var flat service.OrganizedTree
+	switch tree := result.Tree.(type) {
+	case service.OrganizedTree:
+		flat = tree
+	case *service.FileNode:
+		flat = service.OrganizeFileTree(tree)
+	}
+
+	columns := p.Columns
+	if len(columns) == 0 {
+		columns = defaultColumns
+	}
+
+	w.Header().Set("Content-Type", contentTypes[p.Format])
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": result.Root.Name + "." + p.Format,
+	}))
+	if result.Truncated {
+		w.Header().Set(HeaderTruncated, "true")
+	}
+	if len(result.Errors) > 0 {
+		w.Header().Set(HeaderErrors, strconv.Itoa(len(result.Errors)))
+	}
+
+	writer := csv.NewWriter(w)
+	if p.Format == FormatTSV {
+		writer.Comma = '\t'
+	} else {
+		writer.UseCRLF = true // RFC 4180 line endings
+	}
+
+	writer.Write(columns)
+	record := make([]string, len(columns))
+	for _, nodes := range [][]*service.FileNode{flat.Dirs, flat.Files} {
+		for _, node := range nodes {
+			for i, column := range columns {
+				record[i] = tableValue(node, column)
+			}
+			writer.Write(record)
+		}
+	}
+	writer.Flush()
+}
+
+// Returns the value of a column for a node
+func tableValue(node *service.FileNode, column string) string {
+	switch column {
+	case ColumnPath:
+		return escapeFormula(node.Path)
+	case ColumnName:
+		return escapeFormula(node.Name)
+	case ColumnType:
+		if node.IsDir {
+			return "dir"
+		}
+		if node.IsSymlink {
+			return "symlink"
+		}
+		return "file"
+	case ColumnSize:
+		return strconv.FormatInt(node.Size, 10)
+	case ColumnMtime:
+		return formatTime(node.LastModified)
+	case ColumnCtime:
+		return formatTime(node.CreatedDate)
+	case ColumnHash:
+		return node.Hash
+	}
+
+	return ""
+}
+
+// Formats a Unix time as RFC 3339 in UTC, leaving unknown times empty
+func formatTime(unix int64) string {
+	if unix == 0 {
+		return ""
+	}
+
+	return time.Unix(unix, 0).UTC().Format(time.RFC3339)
+}
+
+// Prefixes text starting like a formula with a quote, so spreadsheets show it as is
+func escapeFormula(value string) string {
+	if value != "" && strings.IndexByte(formulaPrefixes, value[0]) >= 0 {
+		return "'" + value
+	}
+
+	return value
+}
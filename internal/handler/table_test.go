@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"encoding/csv"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"FileTree-API/internal/payload"
+	"FileTree-API/internal/service"
+)
+
+func TestWriteTableEscapesFormulas(t *testing.T) {
+	root := &service.FileNode{Name: "root", Path: "root", IsDir: true, Children: []*service.FileNode{
+		{Name: "=SUM(A1).csv", Path: "root/=SUM(A1).csv", Size: 1},
+		{Name: "+1", Path: "root/+1"},
+		{Name: "-rf", Path: "root/-rf"},
+		{Name: "@home", Path: "root/@home"},
+		{Name: "\tx", Path: "root/\tx"},
+		{Name: "a=b", Path: "root/a=b"},
+	}}
+	result := &service.FileTreeResult{
+		Root:      service.RootInfo{Name: "root"},
+		Tree:      root,
+		Truncated: true,
+		Errors:    []service.EntryError{{}, {}},
+	}
+
+	rec := httptest.NewRecorder()
+	writeTable(rec, result, &payload.Payload{Format: FormatCSV, Columns: []string{ColumnName, ColumnSize}})
+
+	if got := rec.Header().Get(HeaderTruncated); got != "true" {
+		t.Errorf("%s = %q, want %q", HeaderTruncated, got, "true")
+	}
+	if got := rec.Header().Get(HeaderErrors); got != "2" {
+		t.Errorf("%s = %q, want %q", HeaderErrors, got, "2")
+	}
+
+	records, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, record := range records[1:] {
+		names = append(names, record[0])
+	}
+	want := []string{"root", "'=SUM(A1).csv", "'+1", "'-rf", "'@home", "'\tx", "a=b"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("names = %q, want %q", names, want)
+	}
+}
+
+func TestWriteTableHeadersOmitted(t *testing.T) {
+	result := &service.FileTreeResult{Root: service.RootInfo{Name: "root"}, Tree: &service.FileNode{Name: "root", IsDir: true}}
+
+	rec := httptest.NewRecorder()
+	writeTable(rec, result, &payload.Payload{Format: FormatTSV})
+
+	for _, header := range []string{HeaderTruncated, HeaderErrors} {
+		if _, ok := rec.Header()[header]; ok {
+			t.Errorf("%s is set on a complete listing", header)
+		}
+	}
+}
@@ -1,9 +1,10 @@
 package handler
 
 import (
-	"encoding/base64"
+	"context"
 	"net/http"
 
+	"FileTree-API/internal/service"
 	"FileTree-API/internal/utils"
 	"FileTree-API/pkg/api"
 
@@ -16,7 +17,13 @@ type wrapChunkData struct {
 	TotalChunks int    `json:"totalChunks"`
 	Progress    int    `json:"progress"`
 	Complete    bool   `json:"complete"`
-	Data        string `json:"data"`
+	Data        []byte `json:"data"` // Base64 text in JSON, raw bytes in the binary encodings
+}
+
+// matchMessage carries a single search match, sent before the chunked result
+type matchMessage struct {
+	Type  string               `json:"type"`
+	Match *service.SearchMatch `json:"match"`
 }
 
 var json = jsoniter.ConfigCompatibleWithStandardLibrary
@@ -26,21 +33,22 @@ var upgrader = websocket.Upgrader{
 	CheckOrigin: func(r *http.Request) bool {
 		return true
 	},
+	Subprotocols: subprotocols,
 }
 
-func wrapChunks(chunk []byte, index, totalChunks int) ([]byte, error) {
+func wrapChunks(c codec, chunk []byte, index, totalChunks int) ([]byte, error) {
 	data := wrapChunkData{
 		Index:       index,
 		TotalChunks: totalChunks,
 		Progress:    (index + 1) * 100 / totalChunks,
 		Complete:    index == totalChunks-1,
-		Data:        base64.StdEncoding.EncodeToString(chunk),
+		Data:        chunk,
 	}
 
-	return json.Marshal(data)
+	return c.marshal(data)
 }
 
-func sendInChunks(conn *websocket.Conn, data []byte, chunkSize int) error {
+func sendInChunks(conn *websocket.Conn, c codec, data []byte, chunkSize int) error {
 	totalChunks := len(data) / chunkSize
 	if len(data)%chunkSize != 0 {
 		totalChunks++
@@ -54,12 +62,12 @@ func sendInChunks(conn *websocket.Conn, data []byte, chunkSize int) error {
 		}
 		chunk := data[start:end]
 
-		message, err := wrapChunks(chunk, i, totalChunks)
+		message, err := wrapChunks(c, chunk, i, totalChunks)
 		if err != nil {
 			return err
 		}
 
-		if err := conn.WriteMessage(websocket.TextMessage, message); err != nil {
+		if err := conn.WriteMessage(c.messageType, message); err != nil {
 			return err
 		}
 	}
@@ -93,25 +101,58 @@ func WebSocketHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer conn.Close()
 
+	// Cancel the walk when the client closes the connection
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	go func() {
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				cancel()
+				return
+			}
+		}
+	}()
+
+	// Encode the messages according to the negotiated subprotocol
+	c, ok := codecs[conn.Subprotocol()]
+	if !ok {
+		c = codecs[FormatJSON]
+	}
+
+	// Send search matches as soon as they are found, the complete result still follows in chunks
+	onMatch := func(match *service.SearchMatch) {
+		message, err := c.marshal(matchMessage{Type: "match", Match: match})
+		if err != nil {
+			return
+		}
+		if err := conn.WriteMessage(c.messageType, message); err != nil {
+			cancel()
+		}
+	}
+
+	// Streamed nodes are sent in batches, the chunked result then only carries the summary
+	stream := &wsNodeStream{conn: conn, codec: c, cancel: cancel}
+
 	// Get the file tree result
-	fileTreeResult, err := ProcessEncryptedPath(r)
+	fileTreeResult, err := ProcessEncryptedPath(r.WithContext(ctx), Hooks{OnMatch: onMatch, OnNode: stream.write})
+	stream.flush()
 
 	if err != nil {
 		errorMsg := err.Error()
 		apiErrorResponse := api.NewErrorResponse(errorMsg)
-		errJSON, _ := json.Marshal(apiErrorResponse)
-		conn.WriteMessage(websocket.TextMessage, errJSON)
+		errMessage, _ := c.marshal(apiErrorResponse)
+		conn.WriteMessage(c.messageType, errMessage)
 		return
 	}
 
-	result, err := json.Marshal(fileTreeResult)
+	result, err := c.marshal(fileTreeResult)
 	if err != nil {
-		utils.OutputMessage(conn, utils.WebSocketResponse, http.StatusInternalServerError, "Error encoding file tree result to JSON")
+		utils.OutputMessage(conn, utils.WebSocketResponse, http.StatusInternalServerError, "Error encoding file tree result")
 		return
 	}
 
 	chunkSize := 10240
-	if err = sendInChunks(conn, result, chunkSize); err != nil {
+	if err = sendInChunks(conn, c, result, chunkSize); err != nil {
 		utils.OutputMessage(conn, utils.WebSocketResponse, http.StatusInternalServerError, "Failed to send file tree result over WebSocket in chunks")
 		return
 	}
@@ -3,6 +3,7 @@ package middleware
 import (
 	"fmt"
 	"net/http"
+	"strconv"
 
 	"FileTree-API/internal/handler"
 	"FileTree-API/internal/security"
@@ -18,8 +19,12 @@ func SignatureVerificationMiddleware(next http.Handler) http.Handler {
 		signature := vars["signature"]
 		encrypted := vars["encrypted"]
 
-		// Reassemble the URL path for signature verification
-		encPath := fmt.Sprintf("/enc/%s", encrypted)
+		// Get the optional issued-at and expires-at timestamps from the query parameters
+		query := r.URL.Query()
+		issuedAt, expiresAt := query.Get("iat"), query.Get("exp")
+
+		// Reassemble the URL path for signature verification, the timestamps are part of the signed material
+		encPath := fmt.Sprintf("/enc/%s%s", encrypted, security.TimestampQuery(issuedAt, expiresAt))
 
 		// Decode signature
 		_, err := utils.Base64UrlDecode(signature)
@@ -42,7 +47,44 @@ func SignatureVerificationMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		// Check the validity window of the signed link
+		iat, errIat := parseTimestamp(issuedAt)
+		exp, errExp := parseTimestamp(expiresAt)
+		if errIat != nil || errExp != nil {
+			if utils.IsWebSocket(r) {
+				handler.WebSocketMessage(w, r, handler.ErrInvalidTimestamp.Error())
+			} else {
+				utils.OutputMessage(w, utils.HTTPResponse, http.StatusBadRequest, handler.ErrInvalidTimestamp.Error())
+			}
+			return
+		}
+		if err := security.CheckValidity(iat, exp); err != nil {
+			if utils.IsWebSocket(r) {
+				handler.WebSocketMessage(w, r, err.Error())
+			} else {
+				utils.OutputMessage(w, utils.HTTPResponse, handler.DetermineHTTPStatusCode(err), err.Error())
+			}
+			return
+		}
+
 		// Continue processing the rest of the request
 		next.ServeHTTP(w, r)
 	})
 }
+
+// Parses an optional Unix timestamp, an empty value means not set. Zero and negative values are
+// rejected, as they would read as not set and get around the required expiry.
+func parseTimestamp(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	timestamp, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if timestamp <= 0 {
+		return 0, handler.ErrInvalidTimestamp
+	}
+
+	return timestamp, nil
+}
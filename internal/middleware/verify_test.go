@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"FileTree-API/internal/security"
+
+	"github.com/gorilla/mux"
+)
+
+var (
+	testKey  = []byte("0123456789abcdef0123456789abcdef")
+	testSalt = []byte("salt")
+)
+
+// Signs the encrypted parameter and the query the way link issuers do
+func sign(encrypted, query string) string {
+	mac := hmac.New(sha256.New, testKey)
+	mac.Write(testSalt)
+	mac.Write([]byte("/enc/" + encrypted + query))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Sends the request through the middleware, reporting the status code and whether the handler was reached
+func serve(t *testing.T, url string) (int, bool) {
+	t.Helper()
+	reached := false
+	router := mux.NewRouter()
+	router.Handle("/{signature}/enc/{encrypted}", SignatureVerificationMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	})))
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, url, nil))
+
+	return recorder.Code, reached
+}
+
+func TestSignatureVerificationMiddleware(t *testing.T) {
+	security.SetKeyAndSalt(testKey, testSalt)
+	at := time.Unix(1_700_000_000, 0)
+	security.SetClock(func() time.Time { return at })
+	t.Cleanup(func() { security.SetClock(time.Now) })
+
+	const encrypted = "payload"
+	unix := at.Unix()
+	past, future := strconv.FormatInt(unix-60, 10), strconv.FormatInt(unix+60, 10)
+
+	tests := []struct {
+		name    string
+		signed  string // Query covered by the signature
+		sent    string // Query of the request
+		require bool   // Links without expiry are rejected
+		status  int
+		reached bool
+	}{
+		{name: "without timestamps", signed: "", sent: "", status: http.StatusOK, reached: true},
+		{name: "inside the window", signed: "?iat=" + past + "&exp=" + future, sent: "?iat=" + past + "&exp=" + future, status: http.StatusOK, reached: true},
+		{name: "expired", signed: "?exp=" + past, sent: "?exp=" + past, status: http.StatusGone},
+		{name: "not yet valid", signed: "?iat=" + future, sent: "?iat=" + future, status: http.StatusForbidden},
+		{name: "expiry removed", signed: "?exp=" + past, sent: "", status: http.StatusForbidden},
+		{name: "expiry extended", signed: "?exp=" + past, sent: "?exp=" + future, status: http.StatusForbidden},
+		{name: "invalid timestamp", signed: "?exp=soon", sent: "?exp=soon", status: http.StatusBadRequest},
+		{name: "zero expiry", signed: "?exp=0", sent: "?exp=0", status: http.StatusBadRequest},
+		{name: "negative issued at", signed: "?iat=-1", sent: "?iat=-1", status: http.StatusBadRequest},
+		{name: "expiry required", require: true, signed: "?exp=" + future, sent: "?exp=" + future, status: http.StatusOK, reached: true},
+		{name: "expiry missing", require: true, signed: "", sent: "", status: http.StatusForbidden},
+		{name: "negative expiry", require: true, signed: "?exp=-1", sent: "?exp=-1", status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			security.SetRequireExpiry(tt.require)
+			t.Cleanup(func() { security.SetRequireExpiry(false) })
+			status, reached := serve(t, "/"+sign(encrypted, tt.signed)+"/enc/"+encrypted+tt.sent)
+			if status != tt.status || reached != tt.reached {
+				t.Errorf("got status %d, reached %v, want status %d, reached %v", status, reached, tt.status, tt.reached)
+			}
+		})
+	}
+}
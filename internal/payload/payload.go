@@ -0,0 +1,75 @@
+package payload
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"FileTree-API/internal/service"
+	"FileTree-API/internal/utils"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// Version is the newest structured payload format understood by the server
+const Version = 1
+
+// Error declaration for payload parsing
+var (
+	ErrInvalidPayload     = errors.New("invalid payload")
+	ErrUnsupportedVersion = errors.New("unsupported payload version")
+	ErrMissingPath        = errors.New("missing path in payload")
+)
+
+// Payload is the decrypted content of the encrypted URL parameter.
+//
+// The structured form is a JSON object such as
+//
+//	{"v":1,"path":"/data/photos","mode":"org","depth":2,"expires":1735689600}
+//
+// while the legacy form is the plain path with '::' separated flags, e.g. "/data/photos::org".
+type Payload struct {
+	Version int      `json:"v"`
+	Path    string   `json:"path"`
+	Expires int64    `json:"expires,omitempty"` // Unix time after which the payload is rejected, 0 means never
+	Format  string   `json:"format,omitempty"`  // Output format, taken from the Accept header when empty
+	Columns []string `json:"columns,omitempty"` // Columns of the CSV and TSV formats
+	service.Options
+}
+
+// Parse decodes the decrypted payload in either the structured or the legacy form
+func Parse(data string) (*Payload, error) {
+	data = strings.TrimSpace(data)
+	if !strings.HasPrefix(data, "{") {
+		return parseLegacy(data), nil
+	}
+
+	json := jsoniter.ConfigCompatibleWithStandardLibrary
+	p := &Payload{}
+	if err := json.UnmarshalFromString(data, p); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+	if p.Version < 1 || p.Version > Version {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, p.Version)
+	}
+	if p.Path == "" {
+		return nil, ErrMissingPath
+	}
+
+	return p, nil
+}
+
+// Converts the legacy "path::org::depth=N" form into a payload
+func parseLegacy(data string) *Payload {
+	path, organize := utils.CheckOrganize(data)
+	p := &Payload{
+		Version: Version,
+		Path:    path,
+	}
+	p.MaxDepth = utils.CheckDepth(data)
+	if organize {
+		p.Mode = service.ModeOrganize
+	}
+
+	return p
+}
@@ -0,0 +1,96 @@
+package security
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// Fixes the clock, skew and expiry policy for the duration of a test
+func setClock(t *testing.T, at time.Time, skew time.Duration, require bool) {
+	t.Helper()
+	SetClock(func() time.Time { return at })
+	SetClockSkew(skew)
+	SetRequireExpiry(require)
+	t.Cleanup(func() {
+		SetClock(time.Now)
+		SetClockSkew(0)
+		SetRequireExpiry(false)
+	})
+}
+
+func TestCheckValidity(t *testing.T) {
+	at := time.Unix(1_700_000_000, 0)
+	unix := at.Unix()
+
+	tests := []struct {
+		name     string
+		skew     time.Duration
+		require  bool
+		issued   int64
+		expires  int64
+		expected error
+	}{
+		{name: "no timestamps", expected: nil},
+		{name: "inside the window", issued: unix - 60, expires: unix + 60, expected: nil},
+		{name: "not yet valid", issued: unix + 60, expected: ErrLinkNotYetValid},
+		{name: "expired", expires: unix - 60, expected: ErrLinkExpired},
+		{name: "issued inside the skew", skew: 30 * time.Second, issued: unix + 20, expected: nil},
+		{name: "issued beyond the skew", skew: 30 * time.Second, issued: unix + 40, expected: ErrLinkNotYetValid},
+		{name: "expired inside the skew", skew: 30 * time.Second, expires: unix - 20, expected: nil},
+		{name: "expired beyond the skew", skew: 30 * time.Second, expires: unix - 40, expected: ErrLinkExpired},
+		{name: "missing expiry when required", require: true, issued: unix - 60, expected: ErrMissingExpiry},
+		{name: "expiry present when required", require: true, expires: unix + 60, expected: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setClock(t, at, tt.skew, tt.require)
+			if err := CheckValidity(tt.issued, tt.expires); !errors.Is(err, tt.expected) {
+				t.Errorf("CheckValidity(%d, %d) = %v, want %v", tt.issued, tt.expires, err, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCheckExpiry(t *testing.T) {
+	at := time.Unix(1_700_000_000, 0)
+	unix := at.Unix()
+
+	tests := []struct {
+		name     string
+		skew     time.Duration
+		expires  int64
+		expected error
+	}{
+		{name: "not set", expected: nil},
+		{name: "in the future", expires: unix + 1, expected: nil},
+		{name: "in the past", expires: unix - 1, expected: ErrLinkExpired},
+		{name: "inside the skew", skew: time.Minute, expires: unix - 30, expected: nil},
+		{name: "beyond the skew", skew: time.Minute, expires: unix - 90, expected: ErrLinkExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The payload expiry is checked alone, so a missing one is never an error here
+			setClock(t, at, tt.skew, true)
+			if err := CheckExpiry(tt.expires); !errors.Is(err, tt.expected) {
+				t.Errorf("CheckExpiry(%d) = %v, want %v", tt.expires, err, tt.expected)
+			}
+		})
+	}
+}
+
+func TestTimestampQuery(t *testing.T) {
+	tests := []struct {
+		issued, expires, expected string
+	}{
+		{"", "", ""},
+		{"1", "", "?iat=1"},
+		{"", "2", "?exp=2"},
+		{"1", "2", "?iat=1&exp=2"},
+	}
+	for _, tt := range tests {
+		if query := TimestampQuery(tt.issued, tt.expires); query != tt.expected {
+			t.Errorf("TimestampQuery(%q, %q) = %q, want %q", tt.issued, tt.expires, query, tt.expected)
+		}
+	}
+}
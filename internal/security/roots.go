@@ -0,0 +1,201 @@
+package security
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Error declaration for the allowed roots
+var (
+	ErrPathNotAllowed  = errors.New("path is not inside an allowed root")
+	ErrHiddenForbidden = errors.New("hidden files are not allowed for this path")
+)
+
+// Root is a base directory that may be served, addressed by its name
+type Root struct {
+	Name string
+	Path string
+}
+
+// ResolvedPath is a requested path mapped onto the file system
+type ResolvedPath struct {
+	Path  string // Real path on disk, with symlinks resolved
+	Root  *Root  // The allowed root containing Path, nil when no roots are configured
+	Alias string // The path as "name:/relative/path", empty when no roots are configured
+	Named bool   // The path was requested relative to a named root
+}
+
+var (
+	roots        []Root
+	forbidHidden map[string]bool
+)
+
+// SetAllowedRoots sets the roots that may be served, no roots means every path is allowed
+func SetAllowedRoots(r []Root) {
+	roots = r
+}
+
+// SetForbidHidden sets the names of the roots whose hidden files must never be revealed, "*" means every root
+func SetForbidHidden(names []string) {
+	forbidHidden = make(map[string]bool, len(names))
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name != "" {
+			forbidHidden[name] = true
+		}
+	}
+}
+
+// AllowedRoots returns the configured roots
+func AllowedRoots() []Root {
+	return roots
+}
+
+// ParseRoots parses a comma separated list of "name=/path" entries and resolves each path.
+// Entries without a name are named after the last element of their path.
+func ParseRoots(value string) ([]Root, error) {
+	var result []Root
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, dir, found := strings.Cut(entry, "=")
+		if !found {
+			name, dir = "", entry
+		}
+
+		real, err := realPath(dir)
+		if err != nil {
+			return nil, fmt.Errorf("root %q: %w", entry, err)
+		}
+		info, err := os.Stat(real)
+		if err != nil {
+			return nil, fmt.Errorf("root %q: %w", entry, err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("root %q: not a directory", entry)
+		}
+
+		if name == "" {
+			name = filepath.Base(real)
+		}
+		result = append(result, Root{Name: name, Path: real})
+	}
+
+	return result, nil
+}
+
+// ResolvePath maps a requested path onto the file system and checks it against the allowed roots.
+// The path is either absolute, or relative to a named root in the form "name:/relative/path".
+func ResolvePath(requested string) (*ResolvedPath, error) {
+	// Paths relative to a named root
+	if name, rel, found := strings.Cut(requested, ":"); found {
+		if root := findRoot(name); root != nil {
+			// Cleaning against "/" first keeps ".." from climbing above the root
+			rel = path.Clean("/" + filepath.ToSlash(rel))
+			real, err := realPath(filepath.Join(root.Path, filepath.FromSlash(rel)))
+			if err != nil {
+				return nil, err
+			}
+			if !IsWithin(root.Path, real) {
+				return nil, ErrPathNotAllowed
+			}
+			return &ResolvedPath{Path: real, Root: root, Alias: aliasOf(root, real), Named: true}, nil
+		}
+	}
+
+	real, err := realPath(requested)
+	if err != nil {
+		return nil, err
+	}
+	if len(roots) == 0 {
+		return &ResolvedPath{Path: real}, nil
+	}
+	for i := range roots {
+		if IsWithin(roots[i].Path, real) {
+			return &ResolvedPath{Path: real, Root: &roots[i], Alias: aliasOf(&roots[i], real)}, nil
+		}
+	}
+
+	return nil, ErrPathNotAllowed
+}
+
+// CheckHidden rejects paths that are hidden, or that ask for hidden entries, inside a root that forbids them
+func (r *ResolvedPath) CheckHidden(includeHidden bool) error {
+	if !forbidHidden["*"] && (r.Root == nil || !forbidHidden[r.Root.Name]) {
+		return nil
+	}
+	if includeHidden {
+		return ErrHiddenForbidden
+	}
+
+	base := string(filepath.Separator)
+	if r.Root != nil {
+		base = r.Root.Path
+	}
+	if HasHiddenPart(base, r.Path) {
+		return ErrHiddenForbidden
+	}
+
+	return nil
+}
+
+// HasHiddenPart reports whether a part of target below base is hidden, or target is not below base at all
+func HasHiddenPart(base, target string) bool {
+	rel, err := filepath.Rel(base, target)
+	if err != nil {
+		return true
+	}
+	for _, part := range strings.Split(filepath.ToSlash(rel), "/") {
+		if part != "." && strings.HasPrefix(part, ".") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsWithin reports whether target is base itself or lies below it, both paths must be clean and absolute
+func IsWithin(base, target string) bool {
+	rel, err := filepath.Rel(base, target)
+	if err != nil {
+		return false
+	}
+
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
+
+// Returns the "name:/relative/path" form of a path inside the root
+func aliasOf(root *Root, real string) string {
+	rel, err := filepath.Rel(root.Path, real)
+	if err != nil || rel == "." {
+		return root.Name + ":/"
+	}
+
+	return root.Name + ":/" + filepath.ToSlash(rel)
+}
+
+// Finds the allowed root with the given name
+func findRoot(name string) *Root {
+	for i := range roots {
+		if roots[i].Name == name {
+			return &roots[i]
+		}
+	}
+
+	return nil
+}
+
+// Returns the absolute path with every symlink resolved
+func realPath(p string) (string, error) {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.EvalSymlinks(abs)
+}
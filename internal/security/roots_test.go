@@ -0,0 +1,185 @@
+package security
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Creates the directories and symlinks under a temporary directory, and returns its real path
+func makeDirs(t *testing.T, dirs []string, links map[string]string) string {
+	t.Helper()
+	base, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range dirs {
+		if err := os.MkdirAll(filepath.Join(base, filepath.FromSlash(dir)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for link, target := range links {
+		if err := os.Symlink(filepath.FromSlash(target), filepath.Join(base, filepath.FromSlash(link))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return base
+}
+
+// Sets the allowed roots and the hidden policy for the duration of a test
+func setRoots(t *testing.T, r []Root, forbidden ...string) {
+	t.Helper()
+	SetAllowedRoots(r)
+	SetForbidHidden(forbidden)
+	t.Cleanup(func() {
+		SetAllowedRoots(nil)
+		SetForbidHidden(nil)
+	})
+}
+
+func TestResolvePath(t *testing.T) {
+	base := makeDirs(t, []string{"data/sub", "data/.secret", "data2/x"}, map[string]string{
+		"data/in":  "sub",
+		"data/out": "../data2",
+		"data/abs": "/",
+	})
+	data := filepath.Join(base, "data")
+	setRoots(t, []Root{{Name: "data", Path: data}})
+
+	tests := []struct {
+		name      string
+		requested string
+		path      string // Expected real path, empty when an error is expected
+		alias     string
+		named     bool
+		notFound  bool // The error is not ErrPathNotAllowed but the path does not exist
+	}{
+		{name: "root", requested: "data:/", path: data, alias: "data:/", named: true},
+		{name: "named", requested: "data:/sub", path: data + "/sub", alias: "data:/sub", named: true},
+		{name: "dot dot above the root", requested: "data:/../../..", path: data, alias: "data:/", named: true},
+		{name: "dot dot to a sibling", requested: "data:/sub/../../data2", notFound: true},
+		{name: "dot dot without slash", requested: "data:../data2/x", notFound: true},
+		{name: "named link inside", requested: "data:/in", path: data + "/sub", alias: "data:/sub", named: true},
+		{name: "named link outside", requested: "data:/out"},
+		{name: "named absolute link outside", requested: "data:/abs"},
+		{name: "absolute", requested: data + "/sub", path: data + "/sub", alias: "data:/sub"},
+		{name: "absolute link inside", requested: data + "/in", path: data + "/sub", alias: "data:/sub"},
+		{name: "absolute link outside", requested: data + "/out/x"},
+		{name: "sibling prefix", requested: base + "/data2"},
+		{name: "sibling prefix with dot dot", requested: data + "/../data2/x"},
+		{name: "parent of the root", requested: base},
+		{name: "unknown root", requested: "other:/sub", notFound: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolved, err := ResolvePath(tt.requested)
+			if tt.path == "" {
+				if err == nil {
+					t.Fatalf("ResolvePath(%q) = %q, want an error", tt.requested, resolved.Path)
+				}
+				if notAllowed := errors.Is(err, ErrPathNotAllowed); notAllowed == tt.notFound {
+					t.Errorf("ResolvePath(%q) error = %v, want not found %v", tt.requested, err, tt.notFound)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ResolvePath(%q) error = %v", tt.requested, err)
+			}
+			if resolved.Path != filepath.FromSlash(tt.path) || resolved.Alias != tt.alias || resolved.Named != tt.named {
+				t.Errorf("ResolvePath(%q) = %+v, want path %q, alias %q and named %v",
+					tt.requested, resolved, tt.path, tt.alias, tt.named)
+			}
+			if resolved.Root == nil || resolved.Root.Name != "data" {
+				t.Errorf("ResolvePath(%q) root = %v, want data", tt.requested, resolved.Root)
+			}
+		})
+	}
+}
+
+func TestResolvePathWithoutRoots(t *testing.T) {
+	base := makeDirs(t, []string{"data2"}, map[string]string{"link": "data2"})
+	setRoots(t, nil)
+
+	resolved, err := ResolvePath(base + "/link")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved.Path != filepath.Join(base, "data2") || resolved.Root != nil || resolved.Alias != "" {
+		t.Errorf("ResolvePath() = %+v, want the real path without root", resolved)
+	}
+}
+
+func TestIsWithin(t *testing.T) {
+	tests := []struct {
+		base, target string
+		expected     bool
+	}{
+		{"/data", "/data", true},
+		{"/data", "/data/a/b", true},
+		{"/data", "/data/..a", true},
+		{"/data", "/data2", false},
+		{"/data", "/data2/a", false},
+		{"/data", "/dat", false},
+		{"/data", "/", false},
+		{"/data/a", "/data", false},
+		{"/", "/data", true},
+	}
+	for _, tt := range tests {
+		if got := IsWithin(tt.base, tt.target); got != tt.expected {
+			t.Errorf("IsWithin(%q, %q) = %v, want %v", tt.base, tt.target, got, tt.expected)
+		}
+	}
+}
+
+func TestHasHiddenPart(t *testing.T) {
+	tests := []struct {
+		base, target string
+		expected     bool
+	}{
+		{"/data", "/data", false},
+		{"/data", "/data/a/b.txt", false},
+		{"/data", "/data/.a", true},
+		{"/data", "/data/a/.b/c", true},
+		{"/data", "/data/a.b/c", false},
+		{"/data/.hidden", "/data/.hidden/a", false},
+		{"/data", "/data2", true},
+		{"/data", "/", true},
+	}
+	for _, tt := range tests {
+		if got := HasHiddenPart(tt.base, tt.target); got != tt.expected {
+			t.Errorf("HasHiddenPart(%q, %q) = %v, want %v", tt.base, tt.target, got, tt.expected)
+		}
+	}
+}
+
+func TestCheckHidden(t *testing.T) {
+	data := &Root{Name: "data", Path: "/srv/data"}
+	docs := &Root{Name: "docs", Path: "/srv/docs"}
+
+	tests := []struct {
+		name          string
+		forbidden     []string
+		resolved      ResolvedPath
+		includeHidden bool
+		expected      error
+	}{
+		{name: "allowed root", forbidden: []string{"docs"}, resolved: ResolvedPath{Path: "/srv/data/.git", Root: data}, includeHidden: true},
+		{name: "visible path", forbidden: []string{"data"}, resolved: ResolvedPath{Path: "/srv/data/a", Root: data}},
+		{name: "hidden path", forbidden: []string{"data"}, resolved: ResolvedPath{Path: "/srv/data/a/.git/objects", Root: data}, expected: ErrHiddenForbidden},
+		{name: "include hidden", forbidden: []string{"data"}, resolved: ResolvedPath{Path: "/srv/data/a", Root: data}, includeHidden: true, expected: ErrHiddenForbidden},
+		{name: "hidden root directory", forbidden: []string{"*"}, resolved: ResolvedPath{Path: "/srv/.docs", Root: &Root{Name: "d", Path: "/srv/.docs"}}},
+		{name: "every root", forbidden: []string{"*"}, resolved: ResolvedPath{Path: "/srv/docs/.cache", Root: docs}, expected: ErrHiddenForbidden},
+		{name: "without roots", forbidden: []string{"*"}, resolved: ResolvedPath{Path: "/home/.ssh"}, expected: ErrHiddenForbidden},
+		{name: "without roots or policy", resolved: ResolvedPath{Path: "/home/.ssh"}, includeHidden: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setRoots(t, nil, tt.forbidden...)
+			if err := tt.resolved.CheckHidden(tt.includeHidden); !errors.Is(err, tt.expected) {
+				t.Errorf("CheckHidden(%v) = %v, want %v", tt.includeHidden, err, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package service
+
+import "FileTree-API/pkg/api"
+
+// compactEncoder builds an api.CompactTree, sharing equal strings through the string table
+type compactEncoder struct {
+	tree     *api.CompactTree
+	strings  map[string]int
+	modified int64 // Modification time of the previous node
+}
+
+// Encodes the tree into the compact columnar form, listing every node after its parent
+// in the order of the children
+func encodeCompact(root *FileNode) *api.CompactTree {
+	e := &compactEncoder{tree: &api.CompactTree{Root: root.Path}, strings: make(map[string]int)}
+	e.add(root, -1)
+
+	return e.tree
+}
+
+// Adds the node and then its children
+func (e *compactEncoder) add(node *FileNode, parent int) {
+	t := e.tree
+	index := len(t.Parent)
+
+	flags := 0
+	if node.IsDir {
+		flags |= api.FlagDir
+	}
+	if node.IsSymlink {
+		flags |= api.FlagSymlink
+	}
+	if node.HasChildren {
+		flags |= api.FlagHasChildren
+	}
+	if node.Truncated {
+		flags |= api.FlagTruncated
+	}
+	var created int64
+	if node.CreatedDate != 0 {
+		flags |= api.FlagCreated
+		created = node.CreatedDate - node.LastModified
+	}
+	// Directory sizes are totals that the decoder adds up again
+	size := node.Size
+	if node.IsDir {
+		size = 0
+	}
+
+	t.Parent = append(t.Parent, parent)
+	t.Name = append(t.Name, e.intern(node.Name))
+	t.FileType = append(t.FileType, e.intern(node.FileType))
+	t.Size = append(t.Size, size)
+	t.Modified = append(t.Modified, node.LastModified-e.modified)
+	t.Created = append(t.Created, created)
+	t.Flags = append(t.Flags, flags)
+	e.modified = node.LastModified
+
+	// Sparse columns are only created for the first node that needs them
+	if node.Mime != "" && t.Mime == nil {
+		t.Mime = fill(index, -1)
+	}
+	if t.Mime != nil {
+		t.Mime = append(t.Mime, e.intern(node.Mime))
+	}
+	if node.Hash != "" && t.Hash == nil {
+		t.Hash = fill(index, "")
+	}
+	if t.Hash != nil {
+		t.Hash = append(t.Hash, node.Hash)
+	}
+	if node.LinkTarget != "" {
+		if t.LinkTarget == nil {
+			t.LinkTarget = make(map[int]string)
+		}
+		t.LinkTarget[index] = node.LinkTarget
+	}
+	if node.Error != "" {
+		if t.Error == nil {
+			t.Error = make(map[int]string)
+		}
+		t.Error[index] = node.Error
+	}
+
+	for _, child := range node.Children {
+		e.add(child, index)
+	}
+}
+
+// Returns the index of the string in the string table, -1 for the empty string
+func (e *compactEncoder) intern(s string) int {
+	if s == "" {
+		return -1
+	}
+	index, ok := e.strings[s]
+	if !ok {
+		index = len(e.tree.Strings)
+		e.tree.Strings = append(e.tree.Strings, s)
+		e.strings[s] = index
+	}
+
+	return index
+}
+
+// Returns a slice holding n times the value
+func fill[T any](n int, value T) []T {
+	s := make([]T, n)
+	for i := range s {
+		s[i] = value
+	}
+
+	return s
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"FileTree-API/pkg/api"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// Decodes the JSON encoding of a value into generic maps, so trees of different types can be compared
+func generic(t *testing.T, v interface{}) interface{} {
+	t.Helper()
+	json := jsoniter.ConfigCompatibleWithStandardLibrary
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	return out
+}
+
+// Checks that the tree survives encodeCompact followed by Decode
+func checkCompactRoundTrip(t *testing.T, tree *FileNode) {
+	t.Helper()
+	decoded, err := encodeCompact(tree).Decode()
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if got, want := generic(t, decoded), generic(t, tree); !reflect.DeepEqual(got, want) {
+		t.Errorf("decoded tree differs:\n got %v\nwant %v", got, want)
+	}
+}
+
+func TestCompactRoundTripWalk(t *testing.T) {
+	base := makeTree(t, map[string]string{
+		"photos/a.txt":        "hello",
+		"photos/2024/b.jpg":   "\xff\xd8\xff\xe0 jpeg",
+		"photos/2024/x/c.png": "\x89PNG\r\n\x1a\n",
+		"photos/2024/x/d":     "",
+		"photos/empty/e.md":   "# e",
+	})
+	root := filepath.Join(base, "photos")
+	if err := os.Symlink("a.txt", filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{name: "absolute", opts: Options{PathMode: PathModeAbsolute, Mime: true, Hash: HashXXHash}},
+		{name: "relative", opts: Options{PathMode: PathModeRelative, Hash: HashSHA256}},
+		{name: "alias", opts: Options{PathMode: PathModeAlias, Scope: Scope{Base: base, Alias: "media:/photos"}, Mime: true}},
+		{name: "depth", opts: Options{PathMode: PathModeRelative, MaxDepth: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := GenerateFileTree(context.Background(), root, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkCompactRoundTrip(t, result.Tree.(*FileNode))
+		})
+	}
+}
+
+func TestCompactRoundTripColumns(t *testing.T) {
+	// Sparse columns start after the first nodes, times go back and forth, and the directory totals
+	// include a nested directory
+	tree := &FileNode{Name: "media:/", Path: "media:/", LastModified: 1_700_000_500, IsDir: true, Children: []*FileNode{
+		{Name: "a", Path: "media:/a", LastModified: 1_700_000_000, IsDir: true, Children: []*FileNode{
+			{Name: "x.jpg", Path: "media:/a/x.jpg", FileType: "jpg", Mime: "image/jpeg", Size: 10, CreatedDate: 1_600_000_000, LastModified: 1_700_000_900, Hash: "aa"},
+			{Name: "y", Path: "media:/a/y", Size: 3, LastModified: 1_500_000_000, Error: "permission denied"},
+		}},
+		{Name: "b", Path: "media:/b", LastModified: 1_700_000_100, IsDir: true, HasChildren: true, Truncated: true},
+		{Name: "l", Path: "media:/l", LastModified: 1_700_000_200, IsSymlink: true, LinkTarget: "a/x.jpg", CreatedDate: 1_700_000_200},
+		{Name: "z.jpg", Path: "media:/z.jpg", FileType: "jpg", Size: 7, LastModified: 1_700_000_300, Hash: "bb"},
+	}}
+	aggregateDir(tree)
+
+	checkCompactRoundTrip(t, tree)
+
+	compact := encodeCompact(tree)
+	if len(compact.Strings) != 9 {
+		t.Errorf("string table has %d entries, want 9 with the shared ones only once: %v", len(compact.Strings), compact.Strings)
+	}
+	if compact.Size[0] != 0 || compact.Size[1] != 0 {
+		t.Errorf("directory sizes are encoded: %v", compact.Size)
+	}
+}
+
+func TestCompactDecodeInvalid(t *testing.T) {
+	tests := map[string]*api.CompactTree{
+		"empty":            {},
+		"missing column":   {Parent: []int{-1}, Name: []int{-1}},
+		"parent after":     {Parent: []int{-1, 2, 0}, Name: []int{-1, -1, -1}, FileType: []int{-1, -1, -1}, Size: make([]int64, 3), Modified: make([]int64, 3), Created: make([]int64, 3), Flags: make([]int, 3)},
+		"unknown string":   {Parent: []int{-1}, Name: []int{3}, FileType: []int{-1}, Size: make([]int64, 1), Modified: make([]int64, 1), Created: make([]int64, 1), Flags: make([]int, 1)},
+		"root with parent": {Parent: []int{0}, Name: []int{-1}, FileType: []int{-1}, Size: make([]int64, 1), Modified: make([]int64, 1), Created: make([]int64, 1), Flags: make([]int, 1)},
+	}
+	for name, tree := range tests {
+		if _, err := tree.Decode(); !errors.Is(err, api.ErrInvalidCompactTree) {
+			t.Errorf("%s: Decode() error = %v, want %v", name, err, api.ErrInvalidCompactTree)
+		}
+	}
+}
+
+func TestCompactRejectsFullMetadata(t *testing.T) {
+	opts := Options{Compact: true, Metadata: MetadataFull}
+	if err := opts.Validate(); !errors.Is(err, ErrInvalidOptions) {
+		t.Errorf("Validate() error = %v, want %v", err, ErrInvalidOptions)
+	}
+}
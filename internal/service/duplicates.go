@@ -0,0 +1,150 @@
+package service
+
+import (
+	"sort"
+	"strconv"
+)
+
+// partialHashLength is the number of leading bytes hashed to rule out most candidates cheaply
+const partialHashLength = 64 * 1024
+
+// DuplicateReport is the result of the duplicates mode
+type DuplicateReport struct {
+	Groups         []*DuplicateGroup `json:"groups"`
+	DuplicateFiles int64             `json:"duplicateFiles"` // Files that are a copy of another one, not counting the first of each group
+	WastedBytes    int64             `json:"wastedBytes"`
+}
+
+// DuplicateGroup is a set of files with identical content
+type DuplicateGroup struct {
+	Size        int64       `json:"size"`
+	Hash        string      `json:"hash"`
+	WastedBytes int64       `json:"wastedBytes"` // Bytes that would be freed by keeping a single copy
+	Files       []*FileNode `json:"files"`
+	Links       []*FileNode `json:"links,omitempty"` // Other names of the files, which share their storage
+}
+
+// Groups the walked files by content. Files are first grouped by size, then by the hash of their
+// first bytes, and only the remaining candidates are hashed in full.
+func (w *walker) findDuplicates() *DuplicateReport {
+	algo := w.opts.Hash
+	if algo == "" {
+		algo = HashSHA256
+	}
+
+	// Files of a unique size cannot have a duplicate, and empty files are not worth reporting
+	bySize := map[int64][]*FileNode{}
+	byKey := map[string][]*FileNode{}
+	for _, node := range w.files {
+		switch {
+		case node.Size == 0:
+		case node.fileKey != "":
+			byKey[node.fileKey] = append(byKey[node.fileKey], node)
+		default:
+			bySize[node.Size] = append(bySize[node.Size], node)
+		}
+	}
+	// Hard links are names of the same file and free nothing when removed, so only the first name
+	// is compared and the others are reported as links of its group
+	links := map[*FileNode][]*FileNode{}
+	for _, names := range byKey {
+		sort.Slice(names, func(i, j int) bool { return names[i].Path < names[j].Path })
+		bySize[names[0].Size] = append(bySize[names[0].Size], names[0])
+		if len(names) > 1 {
+			links[names[0]] = names[1:]
+		}
+	}
+	candidates := keepGroups(bySize)
+
+	// Hash the beginning of the candidates, which tells most files of the same size apart
+	partial := w.hashFiles(algo, flatten(candidates), partialHashLength)
+	byPartial := map[string][]*FileNode{}
+	for _, group := range candidates {
+		for _, node := range group {
+			if sum, ok := partial[node]; ok {
+				key := sizeKey(node.Size) + sum
+				byPartial[key] = append(byPartial[key], node)
+			}
+		}
+	}
+	candidates = keepGroups(byPartial)
+
+	// Hash the remaining candidates in full, small files were already hashed completely
+	var large []*FileNode
+	for _, node := range flatten(candidates) {
+		if node.Size > partialHashLength {
+			large = append(large, node)
+		}
+	}
+	full := w.hashFiles(algo, large, 0)
+	byFull := map[string][]*FileNode{}
+	for _, node := range flatten(candidates) {
+		sum, ok := full[node]
+		if node.Size <= partialHashLength {
+			sum, ok = partial[node]
+		}
+		if ok {
+			node.Hash = sum
+			key := sizeKey(node.Size) + sum
+			byFull[key] = append(byFull[key], node)
+		}
+	}
+
+	report := &DuplicateReport{Groups: []*DuplicateGroup{}}
+	for _, files := range keepGroups(byFull) {
+		wasted := files[0].Size * int64(len(files)-1)
+		group := &DuplicateGroup{
+			Size:        files[0].Size,
+			Hash:        files[0].Hash,
+			WastedBytes: wasted,
+			Files:       files,
+		}
+		for _, node := range files {
+			for _, link := range links[node] {
+				link.Hash = node.Hash
+				group.Links = append(group.Links, link)
+			}
+		}
+		report.Groups = append(report.Groups, group)
+		report.DuplicateFiles += int64(len(files) - 1)
+		report.WastedBytes += wasted
+	}
+
+	// The groups that free the most space come first
+	sort.Slice(report.Groups, func(i, j int) bool {
+		a, b := report.Groups[i], report.Groups[j]
+		if a.WastedBytes != b.WastedBytes {
+			return a.WastedBytes > b.WastedBytes
+		}
+		return a.Hash < b.Hash
+	})
+
+	return report
+}
+
+// Returns the groups that have more than one file
+func keepGroups[K comparable](groups map[K][]*FileNode) [][]*FileNode {
+	var result [][]*FileNode
+	for _, group := range groups {
+		if len(group) > 1 {
+			result = append(result, group)
+		}
+	}
+
+	return result
+}
+
+// Flattens the groups into a single list
+func flatten(groups [][]*FileNode) []*FileNode {
+	var result []*FileNode
+	for _, group := range groups {
+		result = append(result, group...)
+	}
+
+	return result
+}
+
+// Prefixes hashes with the size, so files of different sizes never share a group
+func sizeKey(size int64) string {
+	return strconv.FormatInt(size, 10) + ":"
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDuplicatesHardLinks(t *testing.T) {
+	root := makeTree(t, map[string]string{
+		"a.txt":      "same content",
+		"copy/b.txt": "same content",
+		"other.txt":  "other content",
+	})
+	info, err := os.Stat(filepath.Join(root, "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fileKey(info); !ok {
+		t.Skip("files are not identified on this platform")
+	}
+	links := map[string]string{"link1.txt": "a.txt", "copy/link2.txt": "a.txt", "other-link.txt": "other.txt"}
+	for link, target := range links {
+		if err := os.Link(filepath.Join(root, target), filepath.Join(root, filepath.FromSlash(link))); err != nil {
+			t.Skipf("hard links are not supported: %v", err)
+		}
+	}
+
+	result, err := GenerateFileTree(context.Background(), root, Options{Mode: ModeDuplicates, PathMode: PathModeRelative})
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := result.Tree.(*DuplicateReport)
+
+	// The hard links of other.txt are the same file and not duplicates
+	if len(report.Groups) != 1 {
+		t.Fatalf("got %d groups, want 1", len(report.Groups))
+	}
+	group := report.Groups[0]
+	if len(group.Files) != 2 || len(group.Links) != 2 {
+		t.Errorf("got %d files and %d links, want 2 files and 2 links", len(group.Files), len(group.Links))
+	}
+	if size := int64(len("same content")); group.WastedBytes != size || report.WastedBytes != size || report.DuplicateFiles != 1 {
+		t.Errorf("wasted %d bytes in the group, %d in total and %d duplicate files, want %d, %d and 1",
+			group.WastedBytes, report.WastedBytes, report.DuplicateFiles, size, size)
+	}
+	for _, link := range group.Links {
+		if link.Hash != group.Hash {
+			t.Errorf("%s: hash %q, want %q", link.Path, link.Hash, group.Hash)
+		}
+	}
+}
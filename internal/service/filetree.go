@@ -1,9 +1,14 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"os"
+	"path"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -13,14 +18,31 @@ import (
 )
 
 type FileNode struct {
-	Name         string      `json:"name"`
-	Size         int64       `json:"size,omitempty"`
-	FileType     string      `json:"fileType,omitempty"`
-	Path         string      `json:"path"`
-	CreatedDate  int64       `json:"createdDate,omitempty"`
-	LastModified int64       `json:"lastModified,omitempty"`
-	IsDir        bool        `json:"isDir"`
-	Children     []*FileNode `json:"children,omitempty"`
+	Name           string      `json:"name"`
+	Size           int64       `json:"size,omitempty"` // For directories, the recursive size of the files inside
+	FileType       string      `json:"fileType,omitempty"`
+	Mime           string      `json:"mime,omitempty"`
+	Path           string      `json:"path"`
+	CreatedDate    int64       `json:"createdDate,omitempty"`
+	LastModified   int64       `json:"lastModified,omitempty"`
+	FileCount      int64       `json:"fileCount,omitempty"`      // For directories, the recursive number of files inside
+	NewestModified int64       `json:"newestModified,omitempty"` // For directories, the newest modification time inside
+	IsDir          bool        `json:"isDir"`
+	IsSymlink      bool        `json:"isSymlink,omitempty"`
+	LinkTarget     string      `json:"linkTarget,omitempty"`
+	HasChildren    bool        `json:"hasChildren,omitempty"`
+	Truncated      bool        `json:"truncated,omitempty"`
+	Error          string      `json:"error,omitempty"`
+	Meta           *FileMeta   `json:"meta,omitempty"`
+	Hash           string      `json:"hash,omitempty"`
+	Children       []*FileNode `json:"children,omitempty"`
+	Parent         string      `json:"parent,omitempty"` // Path of the parent directory, only set when the nodes are streamed
+
+	// Filled in during the walk for the passes that run after it, never encoded
+	parent   *FileNode
+	fullPath string
+	modTime  int64
+	fileKey  string
 }
 
 type OrganizedTree struct {
@@ -28,20 +50,104 @@ type OrganizedTree struct {
 	Files []*FileNode `json:"files"`
 }
 
+// Error declaration for walks that did not run to completion
+var (
+	ErrWalkTimeout       = errors.New("file tree walk exceeded the maximum duration")
+	ErrWalkCanceled      = errors.New("file tree walk was canceled")
+	ErrUnreadableEntries = errors.New("some entries could not be read")
+)
+
+// maxWalkDuration limits how long a single walk may take, 0 means no limit
+var maxWalkDuration time.Duration
+
+// SetMaxWalkDuration sets how long a single walk may take before it is aborted
+func SetMaxWalkDuration(d time.Duration) {
+	maxWalkDuration = d
+}
+
+// SchemaVersion is the version of the FileTreeResult JSON schema, increased on incompatible changes
+const SchemaVersion = 1
+
+// FileTreeResult is the result of a walk, as returned by the HTTP and WebSocket handlers
 type FileTreeResult struct {
-	Tree      interface{}
-	DirCount  int64
-	FileCount int64
+	SchemaVersion int          `json:"schemaVersion"`
+	Root          RootInfo     `json:"root"`
+	Tree          interface{}  `json:"tree,omitempty"` // Depends on the mode, left out when the nodes were streamed
+	DirCount      int64        `json:"dirCount"`
+	FileCount     int64        `json:"fileCount"`
+	TotalBytes    int64        `json:"totalBytes"` // Sum of the sizes of the listed files
+	ElapsedMs     int64        `json:"elapsedMs"`  // Time taken by the walk in milliseconds
+	Truncated     bool         `json:"truncated"`  // A limit was reached and some directories are incomplete
+	TruncatedDirs []string     `json:"truncatedDirs,omitempty"`
+	Errors        []EntryError `json:"errors,omitempty"`
+}
+
+// RootInfo describes the requested folder
+type RootInfo struct {
+	Name         string `json:"name"`
+	Path         string `json:"path"`
+	Mode         string `json:"mode"`
+	LastModified int64  `json:"lastModified"`
 }
 
-// GenerateFileTree recursively generates a file tree for the given directory
-func GenerateFileTree(root string, organize bool) (*FileTreeResult, error) {
+// EntryError describes an entry that could not be read during the walk
+type EntryError struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// walker holds the shared state of a single file tree walk
+type walker struct {
+	ctx       context.Context
+	root      string
+	opts      Options
+	filter    *filter
+	search    *searcher // Set in the search mode only
+	wg        sync.WaitGroup
+	sema      chan struct{}
+	dirCount  int64
+	fileCount int64
+	byteCount int64
+
+	// Resource limits
+	depthCapped   bool // The depth was lowered to the server limit
+	nodeCount     int64
+	budgetBytes   int64
+	emitMu        sync.Mutex // Keeps the streamed nodes from being sent at the same time
+	mu            sync.Mutex
+	truncatedDirs []string
+	errors        []EntryError
+	files         []*FileNode // Regular files kept for the passes that run after the walk
+	hashes        *hashPool   // Hashes the streamed files during the walk
+}
+
+// GenerateFileTree recursively generates a file tree for the given directory.
+// The walk stops as soon as ctx is done or the maximum walk duration is exceeded.
+func GenerateFileTree(ctx context.Context, root string, opts Options) (*FileTreeResult, error) {
 	// Start counting time
 	start := time.Now()
-	var dirCount, fileCount int64
+
+	// Make sure the options are usable before touching the file system
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Compile the filters once for the whole walk
+	f, err := newFilter(&opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Compile the search criteria
+	var search *searcher
+	if opts.Mode == ModeSearch {
+		if search, err = newSearcher(opts.Search, opts.OnMatch, opts.Stream); err != nil {
+			return nil, err
+		}
+	}
 
 	// Make sure the path is normalized
-	root, err := filepath.Abs(root)
+	root, err = filepath.Abs(root)
 	if err != nil {
 		return nil, err
 	}
@@ -58,47 +164,125 @@ func GenerateFileTree(root string, organize bool) (*FileTreeResult, error) {
 		return nil, os.ErrNotExist // root is not a directory
 	}
 
+	// Apply the server depth limit on top of the requested depth
+	depthCapped := limits.MaxDepth > 0 && (opts.MaxDepth == 0 || opts.MaxDepth > limits.MaxDepth)
+	if depthCapped {
+		opts.MaxDepth = limits.MaxDepth
+	}
+
+	// Abort walks that take longer than the server allows
+	if maxWalkDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, maxWalkDuration)
+		defer cancel()
+	}
+
+	w := &walker{
+		ctx:    ctx,
+		root:   root,
+		opts:   opts,
+		filter: f,
+		search: search,
+		sema:   make(chan struct{}, runtime.NumCPU()), // Use the number of CPUs for better concurrency control
+
+		depthCapped: depthCapped,
+	}
+
 	// Create the root node
 	rootNode := &FileNode{
-		Name:         filepath.Base(root),
-		Path:         root,
+		Name:         w.rootName(),
+		Path:         w.displayPath(root),
 		LastModified: info.ModTime().Unix(),
 		IsDir:        true,
 	}
-
-	// Use a WaitGroup to wait for all goroutines to finish
-	var wg sync.WaitGroup
-
-	// Use a buffered channel to control the number of goroutines
-	sema := make(chan struct{}, runtime.NumCPU()) // Use the number of CPUs for better concurrency control
-	errCh := make(chan error, 1)                  // Error channel
-	errWg := sync.WaitGroup{}                     // WaitGroup for error channel
+	_, rootNode.Meta = readStat(root, info, opts.Metadata == MetadataFull)
 
 	// Set the root node
-	wg.Add(1)
-	go walkDir(root, rootNode, &wg, sema, errCh, &dirCount, &fileCount)
+	if opts.OnNode != nil {
+		w.emit(rootNode)
+	}
+	// Streamed files are not kept until the walk is done, so they are hashed meanwhile and sent once hashed
+	if opts.OnNode != nil && opts.Hash != "" {
+		w.hashes = w.startHashPool(opts.Hash, 0, func(node *FileNode, sum string) {
+			node.Hash = sum
+			w.emit(node)
+		})
+	}
+	w.wg.Add(1)
+	go w.walkDir(root, rootNode, 1, w.ancestorIgnores(), w.enter(nil, root, info))
 	// Wait for all goroutines to finish
-	wg.Wait()
-	// Close the error channel
-	close(errCh)
-
-	// Error handling routine
-	errWg.Add(1)
-	go func() {
-		defer errWg.Done()
-		for err := range errCh {
-			// Handle errors here, possibly logging them or aggregating into a single error
-			if err != nil {
-				utils.OutputMessage(nil, utils.LogOutput, 0, "Error: %v", err)
-			}
+	w.wg.Wait()
+	if w.hashes != nil {
+		w.hashes.wait()
+	}
+
+	// Compute the checksums once every file is known, the duplicates mode only hashes the candidates
+	var duplicates *DuplicateReport
+	if opts.Mode == ModeDuplicates {
+		duplicates = w.findDuplicates()
+	} else if opts.Hash != "" && w.hashes == nil {
+		for node, sum := range w.hashFiles(opts.Hash, w.files, 0) {
+			node.Hash = sum
 		}
-	}()
-	errWg.Wait() // Wait for the error handling routine to finish
+	}
+	// Keep the checksums computed by this walk for the next ones
+	if err := hashCache.save(); err != nil {
+		utils.OutputMessage(nil, utils.LogOutput, 0, "Error saving hash cache: %v", err)
+	}
+
+	// Report walks that were stopped before completion
+	if err := ctx.Err(); err != nil {
+		utils.OutputMessage(nil, utils.LogOutput, 0, "Walk aborted for %v after %v: %v", root, time.Since(start), err)
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, ErrWalkTimeout
+		}
+		return nil, ErrWalkCanceled
+	}
+
+	// Sort the errors by path so the output does not depend on the traversal order
+	sort.Slice(w.errors, func(i, j int) bool {
+		return w.errors[i].Path < w.errors[j].Path
+	})
+	if opts.FailOnError && len(w.errors) > 0 {
+		return nil, fmt.Errorf("%w: %s: %s", ErrUnreadableEntries, w.errors[0].Path, w.errors[0].Error)
+	}
+
+	// Add up the sizes of every directory now that all of them are complete
+	aggregateDir(rootNode)
+
+	// Sort the children so the output does not depend on the traversal order
+	opts.Sort.sortTree(rootNode)
 
 	var result interface{}
-	if organize {
-		result = OrganizeFileTree(rootNode)
+	if opts.OnNode != nil {
+		utils.OutputMessage(nil, utils.LogOutput, 0, "Streamed file tree for %v", rootNode.Path)
+	} else if opts.Mode == ModeSearch && opts.Stream {
+		utils.OutputMessage(nil, utils.LogOutput, 0, "Streamed search results for %v", rootNode.Path)
+	} else if opts.Mode == ModeDuplicates {
+		for _, group := range duplicates.Groups {
+			opts.Sort.sortNodes(group.Files)
+		}
+		result = duplicates
+		utils.OutputMessage(nil, utils.LogOutput, 0, "Finding duplicate files for %v", rootNode.Path)
+	} else if opts.Mode == ModeSearch {
+		sort.SliceStable(search.matches, func(i, j int) bool {
+			return opts.Sort.less(search.matches[i].Node, search.matches[j].Node)
+		})
+		matches := search.matches
+		if matches == nil {
+			matches = []*SearchMatch{}
+		}
+		result = &SearchResult{Matches: matches, Count: len(matches)}
+		utils.OutputMessage(nil, utils.LogOutput, 0, "Searching file tree for %v", rootNode.Path)
+	} else if opts.Mode == ModeOrganize {
+		organizedTree := OrganizeFileTree(rootNode)
+		opts.Sort.sortNodes(organizedTree.Dirs)
+		opts.Sort.sortNodes(organizedTree.Files)
+		result = organizedTree
 		utils.OutputMessage(nil, utils.LogOutput, 0, "Organizing file tree for %v", rootNode.Path)
+	} else if opts.Compact {
+		result = encodeCompact(rootNode)
+		utils.OutputMessage(nil, utils.LogOutput, 0, "Get compact file tree for %v", rootNode.Path)
 	} else {
 		result = rootNode
 		utils.OutputMessage(nil, utils.LogOutput, 0, "Get file tree for %v", rootNode.Path)
@@ -108,68 +292,352 @@ func GenerateFileTree(root string, organize bool) (*FileTreeResult, error) {
 	elapsed := time.Since(start)
 	utils.OutputMessage(nil, utils.LogOutput, 0, "Total time taken: %v", elapsed)
 
+	sort.Strings(w.truncatedDirs)
 	fileTreeResult := &FileTreeResult{
-		Tree:      result,
-		DirCount:  dirCount,
-		FileCount: fileCount,
+		SchemaVersion: SchemaVersion,
+		Root: RootInfo{
+			Name:         rootNode.Name,
+			Path:         rootNode.Path,
+			Mode:         opts.Mode,
+			LastModified: rootNode.LastModified,
+		},
+		Tree:          result,
+		DirCount:      w.dirCount,
+		FileCount:     w.fileCount,
+		TotalBytes:    w.byteCount,
+		ElapsedMs:     elapsed.Milliseconds(),
+		Truncated:     len(w.truncatedDirs) > 0,
+		TruncatedDirs: w.truncatedDirs,
+		Errors:        w.errors,
 	}
 
 	return fileTreeResult, nil
 }
 
-func walkDir(path string, node *FileNode, wg *sync.WaitGroup, sema chan struct{}, errCh chan error, dirCount, fileCount *int64) {
-	defer wg.Done()
+func (w *walker) walkDir(path string, node *FileNode, depth int, ignores *ignoreList, ancestors *ancestry) {
+	defer w.wg.Done()
 
-	// Acquire a semaphore at the start of walkDir to ensure it's released properly
-	sema <- struct{}{}
+	// Acquire a semaphore at the start of walkDir to ensure it's released properly, unless the walk is stopped meanwhile
+	select {
+	case w.sema <- struct{}{}:
+	case <-w.ctx.Done():
+		return
+	}
 	// Ensure to release semaphore whether the function exits normally or through a return
-	defer func() { <-sema }()
+	defer func() { <-w.sema }()
+
+	// Load the ignore files of this directory on top of the inherited rules
+	if w.opts.IgnoreFiles {
+		var err error
+		if ignores, err = loadIgnoreList(ignores, path); err != nil {
+			w.addError(node, err)
+		}
+	}
 
 	// List entries under the directory
 	entries, err := os.ReadDir(path)
 	if err != nil {
-		errCh <- err // Send the error to the error channel
-		return       // Ignore directories that cannot be read
+		w.addError(node, err) // Report the directory as unreadable
+		return                // Ignore directories that cannot be read
 	}
 
 	for _, entry := range entries {
-		// Skip hidden files and directories
-		if entry.Name()[0] == '.' {
+		// Stop listing as soon as the walk is canceled
+		if w.ctx.Err() != nil {
+			return
+		}
+		// Skip hidden files and directories unless they were asked for
+		if !w.opts.IncludeHidden && isHidden(entry.Name()) {
 			continue
 		}
 		// Get the full path of the entry
 		fullPath := filepath.Join(path, entry.Name())
-		// Node initialization with common properties
-		fileInfo, err := entry.Info() // Get file info for common properties
-		if err != nil {
-			errCh <- err // Send error to the error channel
+		isDir := entry.IsDir()
+		// Symlinks are skipped, reported as links, or followed to their target depending on the symlink mode
+		isLink := entry.Type()&os.ModeSymlink != 0
+		statPath, linkInfo := fullPath, os.FileInfo(nil)
+		if isLink {
+			if w.opts.Symlinks == SymlinkSkip {
+				continue
+			}
+			if w.opts.Symlinks == SymlinkFollow {
+				if target, info := w.followLink(fullPath, ancestors); info != nil {
+					statPath, linkInfo = target, info
+					isDir = info.IsDir()
+				}
+			}
+		}
+		// Skip entries rejected by the include and exclude filters
+		if !w.filter.keep(w.relPath(fullPath), entry.Name(), isDir) {
 			continue
 		}
+		// Skip entries matched by the ignore files
+		if ignores != nil && ignores.ignored(fullPath, entry.Name(), isDir) {
+			continue
+		}
+		// Node initialization with common properties
 		childNode := &FileNode{
-			Name:         entry.Name(),
-			Path:         fullPath,
-			LastModified: fileInfo.ModTime().Unix(), // Set last modified for both files and directories
-			IsDir:        entry.IsDir(),
+			Name:   entry.Name(),
+			Path:   w.displayPath(fullPath),
+			IsDir:  isDir,
+			parent: node,
+		}
+		if isLink {
+			childNode.IsSymlink = true
+			childNode.LinkTarget = w.linkTarget(fullPath)
+		}
+		// Stop listing once the node or byte budget is used up
+		if !w.reserve(childNode) {
+			w.truncate(node)
+			return
 		}
+		fileInfo := linkInfo // Get file info for common properties, from the target of followed links
+		if fileInfo == nil {
+			var err error
+			if fileInfo, err = entry.Info(); err != nil {
+				// Keep the entry so clients can tell it exists but could not be read
+				w.addError(childNode, err)
+				w.addChild(node, childNode)
+				continue
+			}
+		}
+		childNode.LastModified = fileInfo.ModTime().Unix() // Set last modified for both files and directories
 
-		if !entry.IsDir() {
-			atomic.AddInt64(fileCount, 1)
-			// Fill additional fields for files
-			childNode.Size = fileInfo.Size()
+		if !isDir {
+			atomic.AddInt64(&w.fileCount, 1)
+			// Fill additional fields for files, links that are not followed have no size of their own
+			if !isLink || linkInfo != nil {
+				childNode.Size = fileInfo.Size()
+				atomic.AddInt64(&w.byteCount, childNode.Size)
+			}
 			childNode.FileType = strings.TrimPrefix(filepath.Ext(entry.Name()), ".") // Remove dot from the extension
-			childNode.CreatedDate = fileInfo.ModTime().Unix()
+			if w.opts.Mime && (!isLink || linkInfo != nil) {
+				childNode.Mime = detectMime(statPath, fileInfo)
+			}
+			childNode.fullPath = statPath
+			childNode.modTime = fileInfo.ModTime().UnixNano()
+			childNode.fileKey, _ = fileKey(fileInfo)
 		}
 
+		// Read the birth time and the extended metadata that the entry info does not have
+		created, meta := readStat(statPath, fileInfo, w.opts.Metadata == MetadataFull)
+		if !isDir {
+			childNode.CreatedDate = created
+		}
+		childNode.Meta = meta
+
+		// Report search matches as soon as they are found, other entries are not kept in the search mode
+		keep := true
+		if w.search != nil {
+			if keep = w.search.match(childNode, w.relPath(fullPath)); keep {
+				w.search.add(childNode)
+			}
+		}
+		// Keep regular files for the passes that run after the walk, streamed matches are already sent
+		if keep && !isDir && (w.opts.Hash != "" || w.opts.Mode == ModeDuplicates) && fileInfo.Mode().IsRegular() &&
+			(w.search == nil || !w.search.stream) {
+			if w.hashes != nil {
+				// Streamed files are sent by the hash pool once they are hashed
+				childNode.Parent = node.Path
+				w.hashes.add(w.ctx, childNode)
+				continue
+			}
+			w.mu.Lock()
+			w.files = append(w.files, childNode)
+			w.mu.Unlock()
+		}
+
+		descend := false
+		if isDir {
+			atomic.AddInt64(&w.dirCount, 1)
+			if w.opts.MaxDepth > 0 && depth >= w.opts.MaxDepth {
+				// Stop at the depth limit, but let clients know whether there is more to load
+				childNode.Truncated = true
+				childNode.HasChildren = w.hasVisibleEntries(fullPath, ignores)
+				if w.depthCapped && childNode.HasChildren {
+					w.truncate(childNode)
+				}
+			} else {
+				// Searches skip the directories their criteria rule out
+				descend = w.search == nil || w.search.canContain(w.relPath(fullPath))
+			}
+		}
+
+		// Add the entry to its directory before its own children are walked
+		w.addChild(node, childNode)
+
 		// If it is a directory, recursively traverse the directory
-		if entry.IsDir() {
-			atomic.AddInt64(dirCount, 1)
+		if descend {
 			// Use WaitGroup to add a count before recursion
-			wg.Add(1)
-			go walkDir(fullPath, childNode, wg, sema, errCh, dirCount, fileCount)
+			w.wg.Add(1)
+			go w.walkDir(fullPath, childNode, depth+1, ignores, w.enter(ancestors, statPath, fileInfo))
+		}
+	}
+}
+
+// Adds an entry to the children of its directory, or sends it right away when the nodes are streamed.
+// The search mode only keeps its matches.
+func (w *walker) addChild(node, child *FileNode) {
+	if w.search != nil {
+		return
+	}
+	if w.opts.OnNode != nil {
+		child.Parent = node.Path
+		w.emit(child)
+		return
+	}
+	node.Children = append(node.Children, child)
+}
+
+// Sends a node to the OnNode callback, one at a time
+func (w *walker) emit(node *FileNode) {
+	w.emitMu.Lock()
+	defer w.emitMu.Unlock()
+	w.opts.OnNode(node)
+}
+
+// Records an error on the node and in the list of errors of the result
+func (w *walker) addError(node *FileNode, err error) {
+	utils.OutputMessage(nil, utils.LogOutput, 0, "Error: %v", err)
+
+	// The path of the error is already known, and may reveal the real server path
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) {
+		err = pathErr.Err
+	}
+	node.Error = err.Error()
+
+	w.mu.Lock()
+	w.errors = append(w.errors, EntryError{Path: node.Path, Error: node.Error})
+	w.mu.Unlock()
+}
+
+// Loads the ignore files of the directories between the allowed root and the walked root,
+// so that rules dropped at the top of a share also apply when a subfolder is requested
+func (w *walker) ancestorIgnores() *ignoreList {
+	if !w.opts.IgnoreFiles || w.opts.Scope.Base == "" {
+		return nil
+	}
+	rel, err := filepath.Rel(w.opts.Scope.Base, w.root)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return nil
+	}
+
+	var ignores *ignoreList
+	dir := w.opts.Scope.Base
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		if ignores, err = loadIgnoreList(ignores, dir); err != nil {
+			utils.OutputMessage(nil, utils.LogOutput, 0, "Error reading ignore file: %v", err)
 		}
+		dir = filepath.Join(dir, part)
+	}
 
-		// If it is a file, add it to the children list
-		node.Children = append(node.Children, childNode)
+	return ignores
+}
+
+// Returns the path shown in the output for a path below the walked root, according to the path mode
+func (w *walker) displayPath(fullPath string) string {
+	if w.opts.PathMode == PathModeAbsolute {
+		return fullPath
+	}
+	rel := w.relPath(fullPath)
+	if w.opts.PathMode == PathModeRelative {
+		return rel
+	}
+	if rel == "." {
+		return w.opts.Scope.Alias
+	}
+
+	return strings.TrimSuffix(w.opts.Scope.Alias, "/") + "/" + rel
+}
+
+// Returns the slash separated path relative to the walked root
+func (w *walker) relPath(fullPath string) string {
+	rel, err := filepath.Rel(w.root, fullPath)
+	if err != nil {
+		return filepath.ToSlash(fullPath)
+	}
+
+	return filepath.ToSlash(rel)
+}
+
+// Returns the name shown in the output for the walked root
+func (w *walker) rootName() string {
+	if w.opts.PathMode != PathModeAlias {
+		return filepath.Base(w.root)
+	}
+	name, rel, _ := strings.Cut(w.opts.Scope.Alias, ":")
+	if rel == "/" {
+		return name
+	}
+
+	return path.Base(rel)
+}
+
+// Reports whether the directory contains at least one entry that would be listed, given the ignore
+// rules inherited from its parent
+func (w *walker) hasVisibleEntries(path string, ignores *ignoreList) bool {
+	dir, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer dir.Close()
+
+	if w.opts.IgnoreFiles {
+		// Unreadable ignore files are reported once the directory itself is walked
+		ignores, _ = loadIgnoreList(ignores, path)
+	}
+
+	for {
+		entries, err := dir.ReadDir(32)
+		for _, entry := range entries {
+			name := entry.Name()
+			if !w.opts.IncludeHidden && isHidden(name) {
+				continue
+			}
+			fullPath := filepath.Join(path, name)
+			isDir := entry.IsDir()
+			if entry.Type()&os.ModeSymlink != 0 {
+				if w.opts.Symlinks == SymlinkSkip {
+					continue
+				}
+				if w.opts.Symlinks == SymlinkFollow {
+					if info, err := os.Stat(fullPath); err == nil {
+						isDir = info.IsDir()
+					}
+				}
+			}
+			if !w.filter.keep(w.relPath(fullPath), name, isDir) {
+				continue
+			}
+			if ignores == nil || !ignores.ignored(fullPath, name, isDir) {
+				return true
+			}
+		}
+		if err != nil {
+			return false
+		}
+	}
+}
+
+// Reports whether the name is a hidden file or directory
+func isHidden(name string) bool {
+	return name[0] == '.'
+}
+
+// Computes the recursive size, file count and newest modification time of the directory, children first
+func aggregateDir(node *FileNode) {
+	node.Size, node.FileCount, node.NewestModified = 0, 0, 0
+	for _, child := range node.Children {
+		if child.IsDir {
+			aggregateDir(child)
+			node.FileCount += child.FileCount
+			node.NewestModified = max(node.NewestModified, child.NewestModified)
+		} else {
+			node.FileCount++
+		}
+		node.Size += child.Size
+		node.NewestModified = max(node.NewestModified, child.LastModified)
 	}
 }
 
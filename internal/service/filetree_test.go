@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Creates the files under a temporary root, directories are created as needed
+func makeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return root
+}
+
+func TestHasChildrenAtDepthLimit(t *testing.T) {
+	root := makeTree(t, map[string]string{
+		"csv/data.csv":    "a,b",
+		"jpg/photo.jpg":   "",
+		"hidden/.profile": "",
+		"nested/sub/x.md": "",
+	})
+
+	tests := []struct {
+		name string
+		opts Options
+		want map[string]bool
+	}{
+		{
+			name: "default",
+			opts: Options{MaxDepth: 1},
+			want: map[string]bool{"csv": true, "jpg": true, "hidden": false, "nested": true},
+		},
+		{
+			name: "include",
+			opts: Options{MaxDepth: 1, Include: []string{"*.jpg"}},
+			want: map[string]bool{"csv": false, "jpg": true, "hidden": false, "nested": true},
+		},
+		{
+			name: "exclude",
+			opts: Options{MaxDepth: 1, Exclude: []string{"*.csv", "sub"}},
+			want: map[string]bool{"csv": false, "jpg": true, "hidden": false, "nested": false},
+		},
+		{
+			name: "hidden",
+			opts: Options{MaxDepth: 1, IncludeHidden: true},
+			want: map[string]bool{"csv": true, "jpg": true, "hidden": true, "nested": true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := GenerateFileTree(context.Background(), root, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tree := result.Tree.(*FileNode)
+			if len(tree.Children) != len(tt.want) {
+				t.Fatalf("got %d children, want %d", len(tree.Children), len(tt.want))
+			}
+			for _, child := range tree.Children {
+				if !child.Truncated {
+					t.Errorf("%s: not truncated at the depth limit", child.Name)
+				}
+				if want := tt.want[child.Name]; child.HasChildren != want {
+					t.Errorf("%s: hasChildren = %v, want %v", child.Name, child.HasChildren, want)
+				}
+			}
+		})
+	}
+}
+
+func TestHasChildrenIgnoreFiles(t *testing.T) {
+	root := makeTree(t, map[string]string{
+		".gitignore":           "*.log\n",
+		"logs/app.log":         "",
+		"build/.gitignore":     "*\n",
+		"build/out.bin":        "",
+		"docs/.filetreeignore": "*.tmp\n!keep.tmp\n",
+		"docs/keep.tmp":        "",
+		"docs/x.tmp":           "",
+	})
+
+	tests := []struct {
+		name string
+		opts Options
+		want map[string]bool
+	}{
+		{
+			name: "ignore files",
+			opts: Options{MaxDepth: 1, IgnoreFiles: true},
+			want: map[string]bool{"logs": false, "build": false, "docs": true},
+		},
+		{
+			name: "without ignore files",
+			opts: Options{MaxDepth: 1},
+			want: map[string]bool{"logs": true, "build": true, "docs": true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := GenerateFileTree(context.Background(), root, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, child := range result.Tree.(*FileNode).Children {
+				if want := tt.want[child.Name]; child.HasChildren != want {
+					t.Errorf("%s: hasChildren = %v, want %v", child.Name, child.HasChildren, want)
+				}
+			}
+		})
+	}
+}
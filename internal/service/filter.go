@@ -0,0 +1,99 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// filter decides which entries are kept during the walk.
+//
+// Glob patterns without a '/' are matched against the entry name, so "node_modules" or "*.jpg"
+// match at any depth. Other glob patterns and all regular expressions are matched against the
+// slash separated path relative to the walked root.
+type filter struct {
+	include      []string
+	exclude      []string
+	includeRegex []*regexp.Regexp
+	excludeRegex []*regexp.Regexp
+}
+
+// Compiles the include and exclude patterns of the options
+func newFilter(opts *Options) (*filter, error) {
+	f := &filter{}
+
+	for _, patterns := range [][]string{opts.Include, opts.Exclude} {
+		for _, pattern := range patterns {
+			if !doublestar.ValidatePattern(pattern) {
+				return nil, fmt.Errorf("%w: invalid pattern %q", ErrInvalidOptions, pattern)
+			}
+		}
+	}
+	f.include = opts.Include
+	f.exclude = opts.Exclude
+
+	var err error
+	if f.includeRegex, err = compileRegex(opts.IncludeRegex); err != nil {
+		return nil, err
+	}
+	if f.excludeRegex, err = compileRegex(opts.ExcludeRegex); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// Reports whether the entry is kept. Excluded directories are not descended into, and the
+// include patterns only apply to files so that matching files in subdirectories are still found.
+func (f *filter) keep(rel, name string, isDir bool) bool {
+	if matchGlobs(f.exclude, rel, name) || matchRegex(f.excludeRegex, rel) {
+		return false
+	}
+	if isDir || (len(f.include) == 0 && len(f.includeRegex) == 0) {
+		return true
+	}
+
+	return matchGlobs(f.include, rel, name) || matchRegex(f.includeRegex, rel)
+}
+
+// Reports whether any of the glob patterns matches the entry
+func matchGlobs(patterns []string, rel, name string) bool {
+	for _, pattern := range patterns {
+		target := rel
+		if !strings.Contains(pattern, "/") {
+			target = name
+		}
+		if doublestar.MatchUnvalidated(pattern, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Reports whether any of the regular expressions matches the relative path
+func matchRegex(expressions []*regexp.Regexp, rel string) bool {
+	for _, re := range expressions {
+		if re.MatchString(rel) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Compiles a list of regular expressions
+func compileRegex(expressions []string) ([]*regexp.Regexp, error) {
+	var result []*regexp.Regexp
+	for _, expr := range expressions {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid regular expression %q", ErrInvalidOptions, expr)
+		}
+		result = append(result, re)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/zeebo/blake3"
+)
+
+// Hash algorithms available for the file checksums
+const (
+	HashSHA256 = "sha256"
+	HashXXHash = "xxhash"
+	HashBLAKE3 = "blake3"
+)
+
+// hashWorkers is the number of files hashed at the same time, independent of the walk concurrency
+var hashWorkers = runtime.NumCPU()
+
+// SetHashWorkers sets the number of files hashed at the same time
+func SetHashWorkers(n int) {
+	if n > 0 {
+		hashWorkers = n
+	}
+}
+
+// Creates a hasher for the algorithm
+func newHasher(algo string) hash.Hash {
+	switch algo {
+	case HashXXHash:
+		return xxhash.New()
+	case HashBLAKE3:
+		return blake3.New()
+	default:
+		return sha256.New()
+	}
+}
+
+// hashPool hashes files with a bounded number of workers, independent of the walk concurrency,
+// reusing the cached checksums of unchanged files
+type hashPool struct {
+	jobs chan *FileNode
+	wg   sync.WaitGroup
+}
+
+// Starts the workers. Only the first limit bytes are hashed when limit is greater than 0.
+// done receives every file once it is hashed, with an empty sum when it could not be read,
+// which is then reported on its node.
+func (w *walker) startHashPool(algo string, limit int64, done func(node *FileNode, sum string)) *hashPool {
+	p := &hashPool{jobs: make(chan *FileNode)}
+	for i := 0; i < hashWorkers; i++ {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for node := range p.jobs {
+				sum, err := hashCache.get(w.ctx, algo, node, limit)
+				if err != nil {
+					w.addError(node, err)
+				}
+				done(node, sum)
+			}
+		}()
+	}
+
+	return p
+}
+
+// Queues a file, waiting for a free worker unless the walk is stopped meanwhile
+func (p *hashPool) add(ctx context.Context, node *FileNode) {
+	select {
+	case p.jobs <- node:
+	case <-ctx.Done():
+	}
+}
+
+// Waits until every queued file is hashed
+func (p *hashPool) wait() {
+	close(p.jobs)
+	p.wg.Wait()
+}
+
+// Hashes the files once the walk is done. Files that cannot be read are reported on their
+// node and left out of the result.
+func (w *walker) hashFiles(algo string, nodes []*FileNode, limit int64) map[*FileNode]string {
+	sums := make(map[*FileNode]string, len(nodes))
+	var mu sync.Mutex
+
+	pool := w.startHashPool(algo, limit, func(node *FileNode, sum string) {
+		if sum == "" {
+			return
+		}
+		mu.Lock()
+		sums[node] = sum
+		mu.Unlock()
+	})
+	for _, node := range nodes {
+		if w.ctx.Err() != nil {
+			break
+		}
+		pool.add(w.ctx, node)
+	}
+	pool.wait()
+
+	return sums
+}
+
+// Hashes the content of a file, only its first limit bytes when limit is greater than 0
+func hashContent(ctx context.Context, algo, path string, limit int64) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	var reader io.Reader = &contextReader{ctx: ctx, r: file}
+	if limit > 0 {
+		reader = io.LimitReader(reader, limit)
+	}
+	h := newHasher(algo)
+	if _, err := io.Copy(h, reader); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// contextReader stops reading once the context is done, so big files do not delay a canceled walk
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return c.r.Read(p)
+}
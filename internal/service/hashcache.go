@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// defaultHashCacheSize is the number of checksums kept when no size is set
+const defaultHashCacheSize = 100_000
+
+// fileHashCache keeps the checksums of files on disk, so unchanged files are not hashed again.
+// Entries are keyed by algorithm, length and path, and are only valid while the size,
+// modification time and inode of the file stay the same. Once the cache holds more than
+// maxEntries checksums, the least recently used ones are dropped, so the checksums of deleted
+// and renamed files do not stay forever.
+type fileHashCache struct {
+	mu         sync.Mutex
+	path       string // File the cache is stored in, empty disables the cache
+	entries    map[string]hashCacheEntry
+	dirty      bool
+	maxEntries int
+
+	saveMu sync.Mutex // Held while the cache is written, so an older copy never replaces a newer one
+}
+
+// hashCacheEntry is a cached checksum and the state of the file it was computed from
+type hashCacheEntry struct {
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mtime"` // Nanoseconds since the Unix epoch
+	FileKey string `json:"key,omitempty"`
+	Hash    string `json:"hash"`
+	Used    int64  `json:"used,omitempty"` // Unix time the checksum was last computed or read
+}
+
+// hashCache is shared by every walk
+var hashCache = &fileHashCache{entries: map[string]hashCacheEntry{}, maxEntries: defaultHashCacheSize}
+
+// SetHashCacheSize sets the number of checksums kept in the cache, 0 keeps the default
+func SetHashCacheSize(n int) {
+	if n > 0 {
+		hashCache.mu.Lock()
+		hashCache.maxEntries = n
+		hashCache.mu.Unlock()
+	}
+}
+
+// SetHashCache loads the checksums stored in the file, and stores new ones there after every walk
+func SetHashCache(path string) error {
+	hashCache.mu.Lock()
+	defer hashCache.mu.Unlock()
+
+	hashCache.path = path
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	json := jsoniter.ConfigCompatibleWithStandardLibrary
+	return json.Unmarshal(data, &hashCache.entries)
+}
+
+// Returns the checksum of the file, from the cache when the file did not change since it was hashed
+func (c *fileHashCache) get(ctx context.Context, algo string, node *FileNode, limit int64) (string, error) {
+	if c.path == "" {
+		return hashContent(ctx, algo, node.fullPath, limit)
+	}
+	key := algo + ":" + strconv.FormatInt(limit, 10) + ":" + node.fullPath
+
+	// Hits only refresh the time of use, which is written with the next change of the cache
+	now := time.Now().Unix()
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	ok = ok && entry.Size == node.Size && entry.ModTime == node.modTime && entry.FileKey == node.fileKey
+	if ok {
+		entry.Used = now
+		c.entries[key] = entry
+	}
+	c.mu.Unlock()
+	if ok {
+		return entry.Hash, nil
+	}
+
+	sum, err := hashContent(ctx, algo, node.fullPath, limit)
+	if err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	c.entries[key] = hashCacheEntry{Size: node.Size, ModTime: node.modTime, FileKey: node.fileKey, Hash: sum, Used: now}
+	c.dirty = true
+	c.mu.Unlock()
+
+	return sum, nil
+}
+
+// Writes the cache to its file if it changed, through a temporary file so readers never see a partial cache.
+// The entries are copied under the lock, and marshaled and written without holding up the hash workers.
+func (c *fileHashCache) save() error {
+	c.saveMu.Lock()
+	defer c.saveMu.Unlock()
+
+	c.mu.Lock()
+	if c.path == "" || !c.dirty {
+		c.mu.Unlock()
+		return nil
+	}
+	path, maxEntries := c.path, c.maxEntries
+	entries := make(map[string]hashCacheEntry, len(c.entries))
+	for key, entry := range c.entries {
+		entries[key] = entry
+	}
+	c.dirty = false
+	c.mu.Unlock()
+
+	if evicted := evictHashes(entries, maxEntries); len(evicted) > 0 {
+		c.mu.Lock()
+		for key, used := range evicted {
+			// Keep the entries used again meanwhile
+			if entry, ok := c.entries[key]; ok && entry.Used <= used {
+				delete(c.entries, key)
+			}
+		}
+		c.mu.Unlock()
+	}
+
+	if err := writeHashCache(path, entries); err != nil {
+		c.mu.Lock()
+		c.dirty = true
+		c.mu.Unlock()
+		return err
+	}
+
+	return nil
+}
+
+// Drops the least recently used entries once there are more than maxEntries, down to nine tenths
+// of it so the next saves do not have to evict again. Returns the time of use of the dropped entries.
+func evictHashes(entries map[string]hashCacheEntry, maxEntries int) map[string]int64 {
+	if maxEntries <= 0 || len(entries) <= maxEntries {
+		return nil
+	}
+
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return entries[keys[i]].Used < entries[keys[j]].Used
+	})
+
+	evicted := map[string]int64{}
+	for _, key := range keys[:len(keys)-maxEntries*9/10] {
+		evicted[key] = entries[key].Used
+		delete(entries, key)
+	}
+
+	return evicted
+}
+
+// Writes the entries to the file through a temporary file
+func writeHashCache(path string, entries map[string]hashCacheEntry) error {
+	json := jsoniter.ConfigCompatibleWithStandardLibrary
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
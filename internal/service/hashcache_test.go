@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+func TestEvictHashes(t *testing.T) {
+	entries := map[string]hashCacheEntry{}
+	for i := 1; i <= 25; i++ {
+		entries[strconv.Itoa(i)] = hashCacheEntry{Hash: "h", Used: int64(i)}
+	}
+
+	evicted := evictHashes(entries, 20)
+	if len(entries) != 18 || len(evicted) != 7 {
+		t.Fatalf("kept %d and evicted %d entries, want 18 and 7", len(entries), len(evicted))
+	}
+	for key, used := range evicted {
+		if used > 7 {
+			t.Errorf("entry %s used at %d was evicted before older ones", key, used)
+		}
+	}
+	if evictHashes(entries, 20) != nil || evictHashes(entries, 0) != nil {
+		t.Error("entries evicted below the limit")
+	}
+}
+
+func TestHashCacheSave(t *testing.T) {
+	root := makeTree(t, map[string]string{"a": "a", "b": "b", "c": "c"})
+	path := filepath.Join(t.TempDir(), "hashes.json")
+	cache := &fileHashCache{path: path, entries: map[string]hashCacheEntry{
+		"sha256:0:/gone": {Hash: "stale", Used: 1},
+	}, maxEntries: 3}
+
+	for _, name := range []string{"a", "b", "c"} {
+		node := &FileNode{Name: name, Size: 1, fullPath: filepath.Join(root, name)}
+		if _, err := cache.get(context.Background(), HashSHA256, node, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cache.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved map[string]hashCacheEntry
+	if err := jsoniter.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := saved["sha256:0:/gone"]; ok {
+		t.Error("the least recently used entry was saved")
+	}
+	if len(saved) != 2 || len(cache.entries) != 2 {
+		t.Errorf("saved %d and kept %d entries, want 2", len(saved), len(cache.entries))
+	}
+	if cache.dirty {
+		t.Error("cache still dirty after saving")
+	}
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// IgnoreFileNames are the ignore files honored by the ignoreFiles option, later files take precedence
+var IgnoreFileNames = []string{".gitignore", ".filetreeignore"}
+
+// ignoreRule is a single line of an ignore file
+type ignoreRule struct {
+	pattern  string
+	negate   bool // The pattern started with '!' and re-includes matching entries
+	dirOnly  bool // The pattern ended with '/' and only matches directories
+	anchored bool // The pattern contained a '/' and is matched against the path relative to the ignore file
+}
+
+// ignoreList holds the rules loaded in one directory, chained to the rules inherited from its parents
+type ignoreList struct {
+	parent *ignoreList
+	dir    string // Directory containing the ignore files the rules were loaded from
+	rules  []ignoreRule
+}
+
+// Loads the ignore files of a directory on top of the inherited rules.
+// The inherited list is returned as is when the directory has no ignore file.
+func loadIgnoreList(parent *ignoreList, dir string) (*ignoreList, error) {
+	var rules []ignoreRule
+	for _, name := range IgnoreFileNames {
+		fileRules, err := readIgnoreFile(filepath.Join(dir, name))
+		if err != nil {
+			return parent, err
+		}
+		rules = append(rules, fileRules...)
+	}
+	if len(rules) == 0 {
+		return parent, nil
+	}
+
+	return &ignoreList{parent: parent, dir: dir, rules: rules}, nil
+}
+
+// Reads the rules of an ignore file, a missing file has no rules
+func readIgnoreFile(path string) ([]ignoreRule, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if rule, ok := parseIgnoreRule(scanner.Text()); ok {
+			rules = append(rules, rule)
+		}
+	}
+
+	return rules, scanner.Err()
+}
+
+// Parses one line of an ignore file following the gitignore syntax
+func parseIgnoreRule(line string) (ignoreRule, bool) {
+	rule := ignoreRule{}
+
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || line[0] == '#' {
+		return rule, false
+	}
+	if line[0] == '!' {
+		rule.negate = true
+		line = line[1:]
+	} else if line[0] == '\\' && len(line) > 1 && (line[1] == '#' || line[1] == '!') {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		rule.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	if line == "" || !doublestar.ValidatePattern(line) {
+		return rule, false
+	}
+	rule.pattern = line
+
+	return rule, true
+}
+
+// Reports whether the entry is ignored. Rules of deeper directories and later lines win,
+// so the first match found from the end of the chain decides.
+func (l *ignoreList) ignored(fullPath, name string, isDir bool) bool {
+	for list := l; list != nil; list = list.parent {
+		for i := len(list.rules) - 1; i >= 0; i-- {
+			rule := list.rules[i]
+			if rule.dirOnly && !isDir {
+				continue
+			}
+			target := name
+			if rule.anchored {
+				rel, err := filepath.Rel(list.dir, fullPath)
+				if err != nil {
+					continue
+				}
+				target = filepath.ToSlash(rel)
+			}
+			if doublestar.MatchUnvalidated(rule.pattern, target) {
+				return !rule.negate
+			}
+		}
+	}
+
+	return false
+}
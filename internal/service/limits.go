@@ -0,0 +1,45 @@
+package service
+
+import (
+	"sync/atomic"
+)
+
+// Limits caps the resources a single walk may use, 0 means no limit
+type Limits struct {
+	MaxNodes int64 // Number of entries in the result
+	MaxDepth int   // Levels below the requested folder, requests asking for more are capped
+	MaxBytes int64 // Estimated size of the encoded result
+}
+
+// nodeOverhead is the estimated encoded size of a node besides its strings
+const nodeOverhead = 128
+
+var limits Limits
+
+// SetLimits sets the resource limits applied to every walk
+func SetLimits(l Limits) {
+	limits = l
+}
+
+// Reserves the budget for one more node, reports false once a limit is reached
+func (w *walker) reserve(node *FileNode) bool {
+	if limits.MaxNodes > 0 && atomic.AddInt64(&w.nodeCount, 1) > limits.MaxNodes {
+		return false
+	}
+	size := int64(nodeOverhead + len(node.Name) + len(node.Path) + len(node.FileType))
+	if limits.MaxBytes > 0 && atomic.AddInt64(&w.budgetBytes, size) > limits.MaxBytes {
+		return false
+	}
+
+	return true
+}
+
+// Marks the directory as cut short by a limit and records it for the result
+func (w *walker) truncate(node *FileNode) {
+	node.Truncated = true
+	node.HasChildren = true
+
+	w.mu.Lock()
+	w.truncatedDirs = append(w.truncatedDirs, node.Path)
+	w.mu.Unlock()
+}
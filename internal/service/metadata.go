@@ -0,0 +1,63 @@
+package service
+
+import (
+	"os/user"
+	"strconv"
+	"sync"
+)
+
+// Metadata levels deciding how much stat information is added to the nodes
+const (
+	// MetadataBasic only adds sizes, timestamps and file types
+	MetadataBasic = "basic"
+	// MetadataFull also adds the Meta field with ownership, permissions and inode information
+	MetadataFull = "full"
+)
+
+// FileMeta is the extended stat information of an entry, added with the full metadata level
+type FileMeta struct {
+	Mode       string `json:"mode"`                 // Permission bits such as "-rw-r--r--"
+	AccessTime int64  `json:"accessTime,omitempty"` // Last access time
+	ChangeTime int64  `json:"changeTime,omitempty"` // Last status change time
+	Uid        uint32 `json:"uid"`
+	Gid        uint32 `json:"gid"`
+	User       string `json:"user,omitempty"`
+	Group      string `json:"group,omitempty"`
+	Inode      uint64 `json:"inode,omitempty"`
+	Links      uint64 `json:"links,omitempty"`
+	Device     uint64 `json:"device,omitempty"`
+}
+
+// Caches of the resolved user and group names, shared by every walk
+var (
+	userNames  sync.Map
+	groupNames sync.Map
+)
+
+// Resolves a user ID to its name, an unknown ID resolves to an empty name
+func lookupUser(uid uint32) string {
+	if name, ok := userNames.Load(uid); ok {
+		return name.(string)
+	}
+	name := ""
+	if u, err := user.LookupId(strconv.FormatUint(uint64(uid), 10)); err == nil {
+		name = u.Username
+	}
+	userNames.Store(uid, name)
+
+	return name
+}
+
+// Resolves a group ID to its name, an unknown ID resolves to an empty name
+func lookupGroup(gid uint32) string {
+	if name, ok := groupNames.Load(gid); ok {
+		return name.(string)
+	}
+	name := ""
+	if g, err := user.LookupGroupId(strconv.FormatUint(uint64(gid), 10)); err == nil {
+		name = g.Name
+	}
+	groupNames.Store(gid, name)
+
+	return name
+}
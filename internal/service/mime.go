@@ -0,0 +1,48 @@
+package service
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// sniffLength is the number of bytes read from a file to detect its MIME type
+const sniffLength = 512
+
+// genericTypes are the sniffed types that only describe a container or a fallback,
+// the extension is more precise when it is known
+var genericTypes = map[string]bool{
+	"application/octet-stream":  true,
+	"application/zip":           true,
+	"text/plain; charset=utf-8": true,
+	"text/xml; charset=utf-8":   true,
+}
+
+// Detects the MIME type of a file from its first bytes, refined by the extension table.
+// Only regular files are read, so devices and pipes never block the walk.
+func detectMime(path string, info os.FileInfo) string {
+	byExt := mime.TypeByExtension(filepath.Ext(info.Name()))
+	if !info.Mode().IsRegular() {
+		return byExt
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return byExt
+	}
+	defer file.Close()
+
+	buf := make([]byte, sniffLength)
+	n, err := io.ReadFull(file, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return byExt
+	}
+	sniffed := http.DetectContentType(buf[:n])
+	if byExt != "" && genericTypes[sniffed] {
+		return byExt
+	}
+
+	return sniffed
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Modes supported by GenerateFileTree
+const (
+	// ModeTree returns the nested file tree
+	ModeTree = "tree"
+	// ModeOrganize returns the flat lists of directories and files
+	ModeOrganize = "org"
+	// ModeDuplicates returns the groups of files with identical content
+	ModeDuplicates = "duplicates"
+	// ModeSearch returns the entries meeting the search criteria
+	ModeSearch = "search"
+)
+
+// Path modes deciding how FileNode paths are written
+const (
+	// PathModeAbsolute writes the absolute path on the server
+	PathModeAbsolute = "absolute"
+	// PathModeRelative writes the path relative to the requested folder, which itself is "."
+	PathModeRelative = "relative"
+	// PathModeAlias writes the path relative to its allowed root, prefixed with the root name
+	PathModeAlias = "alias"
+)
+
+// ErrInvalidOptions is returned when the requested options cannot be used
+var ErrInvalidOptions = errors.New("invalid options")
+
+// SortOptions describes how the children of a directory are ordered
+type SortOptions struct {
+	By        string `json:"by,omitempty"`
+	Order     string `json:"order,omitempty"`
+	DirsFirst bool   `json:"dirsFirst,omitempty"`
+}
+
+// Scope is filled in by the server from the resolved request path, never from the payload
+type Scope struct {
+	Base  string // The allowed root containing the walked root, empty when no roots are configured
+	Alias string // The walked root relative to its allowed root, e.g. "media:/photos/2024"
+}
+
+// Options controls how a file tree is generated
+type Options struct {
+	Mode          string         `json:"mode,omitempty"`
+	MaxDepth      int            `json:"depth,omitempty"`
+	Include       []string       `json:"include,omitempty"`
+	Exclude       []string       `json:"exclude,omitempty"`
+	IncludeRegex  []string       `json:"includeRegex,omitempty"`
+	ExcludeRegex  []string       `json:"excludeRegex,omitempty"`
+	Sort          *SortOptions   `json:"sort,omitempty"`
+	PathMode      string         `json:"pathMode,omitempty"`
+	IncludeHidden bool           `json:"includeHidden,omitempty"`
+	IgnoreFiles   bool           `json:"ignoreFiles,omitempty"`
+	FailOnError   bool           `json:"failOnError,omitempty"`
+	Metadata      string         `json:"metadata,omitempty"`
+	Symlinks      string         `json:"symlinks,omitempty"`
+	Mime          bool           `json:"mime,omitempty"`
+	Hash          string         `json:"hash,omitempty"`
+	Search        *SearchOptions `json:"search,omitempty"`
+	Stream        bool           `json:"stream,omitempty"`
+	Compact       bool           `json:"compact,omitempty"`
+	Scope         Scope          `json:"-"`
+
+	// OnMatch is set by the handler to receive the matches of the search mode as soon as they are found.
+	// Streamed searches only send their matches to it, and the result carries no tree.
+	OnMatch func(*SearchMatch) `json:"-"`
+	// OnNode is set by the handler when the nodes are streamed. Every node is sent to it as soon as it is
+	// found, with its Parent path and without its children, and the result carries no tree.
+	OnNode func(*FileNode) `json:"-"`
+}
+
+// Validate checks the options and fills in the defaults
+func (o *Options) Validate() error {
+	switch o.Mode {
+	case "":
+		o.Mode = ModeTree
+	case ModeTree, ModeOrganize, ModeDuplicates, ModeSearch:
+	default:
+		return fmt.Errorf("%w: unknown mode %q", ErrInvalidOptions, o.Mode)
+	}
+
+	switch o.PathMode {
+	case "":
+		o.PathMode = PathModeAbsolute
+	case PathModeAbsolute, PathModeRelative:
+	case PathModeAlias:
+		if o.Scope.Alias == "" {
+			return fmt.Errorf("%w: path mode %q needs the folder to be inside an allowed root", ErrInvalidOptions, o.PathMode)
+		}
+	default:
+		return fmt.Errorf("%w: unknown path mode %q", ErrInvalidOptions, o.PathMode)
+	}
+
+	switch o.Metadata {
+	case "":
+		o.Metadata = MetadataBasic
+	case MetadataBasic, MetadataFull:
+	default:
+		return fmt.Errorf("%w: unknown metadata level %q", ErrInvalidOptions, o.Metadata)
+	}
+
+	switch o.Symlinks {
+	case "":
+		o.Symlinks = SymlinkLink
+	case SymlinkLink, SymlinkFollow, SymlinkSkip:
+	default:
+		return fmt.Errorf("%w: unknown symlink mode %q", ErrInvalidOptions, o.Symlinks)
+	}
+
+	switch o.Hash {
+	case "", HashSHA256, HashXXHash, HashBLAKE3:
+	default:
+		return fmt.Errorf("%w: unknown hash algorithm %q", ErrInvalidOptions, o.Hash)
+	}
+
+	if o.Sort == nil {
+		o.Sort = &SortOptions{}
+	}
+	if err := o.Sort.validate(); err != nil {
+		return err
+	}
+
+	if o.Stream && o.Mode == ModeDuplicates {
+		return fmt.Errorf("%w: the %q mode cannot be streamed", ErrInvalidOptions, o.Mode)
+	}
+
+	if o.Compact && (o.Mode != ModeTree || o.Stream) {
+		return fmt.Errorf("%w: compact trees need the tree mode and cannot be streamed", ErrInvalidOptions)
+	}
+	if o.Compact && o.Metadata == MetadataFull {
+		return fmt.Errorf("%w: compact trees do not carry the full metadata", ErrInvalidOptions)
+	}
+
+	if o.MaxDepth < 0 {
+		return fmt.Errorf("%w: depth must not be negative", ErrInvalidOptions)
+	}
+
+	return nil
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// Entry types a search can be restricted to
+const (
+	SearchFiles = "file"
+	SearchDirs  = "dir"
+)
+
+// SearchOptions are the criteria of the search mode, an entry matches when it meets all of them
+type SearchOptions struct {
+	Name           string   `json:"name,omitempty"`  // Case-insensitive substring of the name
+	Glob           string   `json:"glob,omitempty"`  // Matched like the include and exclude patterns
+	Regex          string   `json:"regex,omitempty"` // Matched against the path relative to the requested folder
+	Ext            []string `json:"ext,omitempty"`   // File extensions, without the dot
+	Type           string   `json:"type,omitempty"`  // Only files or only directories
+	MinSize        int64    `json:"minSize,omitempty"`
+	MaxSize        int64    `json:"maxSize,omitempty"`
+	ModifiedAfter  int64    `json:"modifiedAfter,omitempty"`
+	ModifiedBefore int64    `json:"modifiedBefore,omitempty"`
+	Ancestors      bool     `json:"ancestors,omitempty"` // Add the chain of parent directories to every match
+}
+
+// SearchResult is the result of the search mode
+type SearchResult struct {
+	Matches []*SearchMatch `json:"matches"`
+	Count   int            `json:"count"`
+}
+
+// SearchMatch is an entry meeting the search criteria
+type SearchMatch struct {
+	Node      *FileNode   `json:"node"`
+	Ancestors []*FileNode `json:"ancestors,omitempty"` // From the requested folder down to the parent of the entry
+}
+
+// searcher holds the compiled search criteria and the matches found so far
+type searcher struct {
+	opts    *SearchOptions
+	name    string
+	regex   *regexp.Regexp
+	ext     map[string]bool
+	onMatch func(*SearchMatch)
+	stream  bool // Matches are only sent to onMatch and not kept
+
+	// Directories outside the fixed leading directories of a glob, or deeper than its number of
+	// segments when it has no "**", cannot contain matches
+	globBase  string
+	globDepth int
+
+	mu      sync.Mutex
+	matches []*SearchMatch
+	sendMu  sync.Mutex // Serializes the onMatch calls without holding up the other walkers
+}
+
+// Checks and compiles the search criteria
+func newSearcher(opts *SearchOptions, onMatch func(*SearchMatch), stream bool) (*searcher, error) {
+	if opts == nil {
+		return nil, fmt.Errorf("%w: the search mode needs search criteria", ErrInvalidOptions)
+	}
+	s := &searcher{opts: opts, name: strings.ToLower(opts.Name), onMatch: onMatch, stream: stream}
+
+	switch opts.Type {
+	case "", SearchFiles, SearchDirs:
+	default:
+		return nil, fmt.Errorf("%w: unknown search type %q", ErrInvalidOptions, opts.Type)
+	}
+	if opts.Glob != "" && !doublestar.ValidatePattern(opts.Glob) {
+		return nil, fmt.Errorf("%w: invalid pattern %q", ErrInvalidOptions, opts.Glob)
+	}
+	if strings.Contains(opts.Glob, "/") {
+		if base, _ := doublestar.SplitPattern(opts.Glob); base != "." {
+			s.globBase = base
+		}
+		if !strings.Contains(opts.Glob, "**") {
+			s.globDepth = strings.Count(opts.Glob, "/") + 1
+		}
+	}
+	if opts.Regex != "" {
+		re, err := regexp.Compile(opts.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid regular expression %q", ErrInvalidOptions, opts.Regex)
+		}
+		s.regex = re
+	}
+	if len(opts.Ext) > 0 {
+		s.ext = make(map[string]bool, len(opts.Ext))
+		for _, ext := range opts.Ext {
+			s.ext[strings.ToLower(strings.TrimPrefix(ext, "."))] = true
+		}
+	}
+
+	return s, nil
+}
+
+// Reports whether the entry meets every criterion. Sizes and extensions only describe files,
+// so directories never match when they are used.
+func (s *searcher) match(node *FileNode, rel string) bool {
+	o := s.opts
+	fileOnly := len(s.ext) > 0 || o.MinSize > 0 || o.MaxSize > 0
+	if node.IsDir && (o.Type == SearchFiles || fileOnly) || !node.IsDir && o.Type == SearchDirs {
+		return false
+	}
+	if s.name != "" && !strings.Contains(strings.ToLower(node.Name), s.name) {
+		return false
+	}
+	if o.Glob != "" && !matchGlobs([]string{o.Glob}, rel, node.Name) {
+		return false
+	}
+	if s.regex != nil && !s.regex.MatchString(rel) {
+		return false
+	}
+	if s.ext != nil && !s.ext[strings.ToLower(node.FileType)] {
+		return false
+	}
+	if o.MinSize > 0 && node.Size < o.MinSize || o.MaxSize > 0 && node.Size > o.MaxSize {
+		return false
+	}
+	if o.ModifiedAfter > 0 && node.LastModified < o.ModifiedAfter || o.ModifiedBefore > 0 && node.LastModified > o.ModifiedBefore {
+		return false
+	}
+
+	return true
+}
+
+// Reports whether the directory, given by its path relative to the requested folder, may contain
+// matches and is worth walking
+func (s *searcher) canContain(rel string) bool {
+	if s.globDepth > 0 && strings.Count(rel, "/")+1 >= s.globDepth {
+		return false
+	}
+	if s.globBase == "" {
+		return true
+	}
+
+	return rel == s.globBase || strings.HasPrefix(s.globBase, rel+"/") || strings.HasPrefix(rel, s.globBase+"/")
+}
+
+// Records a match and reports it right away to the onMatch callback
+func (s *searcher) add(node *FileNode) {
+	match := &SearchMatch{Node: node}
+	if s.opts.Ancestors {
+		// Only the fields set before the walk of a directory starts are copied, the rest is still changing
+		for parent := node.parent; parent != nil; parent = parent.parent {
+			ancestor := &FileNode{Name: parent.Name, Path: parent.Path, LastModified: parent.LastModified, IsDir: true}
+			match.Ancestors = append([]*FileNode{ancestor}, match.Ancestors...)
+		}
+	}
+
+	if !s.stream {
+		s.mu.Lock()
+		s.matches = append(s.matches, match)
+		s.mu.Unlock()
+	}
+
+	if s.onMatch != nil {
+		s.sendMu.Lock()
+		defer s.sendMu.Unlock()
+		s.onMatch(match)
+	}
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSearch(t *testing.T) {
+	root := makeTree(t, map[string]string{
+		"IMG_001.jpg":           "0123456789",
+		"notes.txt":             "abc",
+		"photos/2024/a.JPG":     "01234",
+		"photos/2024/b.png":     "0123456789012345",
+		"photos/old/c.jpg":      "",
+		"src/app/main.go":       "package main",
+		"src/app/util/x.go":     "package util",
+		"src/lib/main_test.go":  "package lib",
+		"src/photos/readme.txt": "",
+	})
+	old := time.Unix(1_600_000_000, 0)
+	for _, name := range []string{"photos/old/c.jpg", "notes.txt"} {
+		if err := os.Chtimes(filepath.Join(root, filepath.FromSlash(name)), old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cutoff := old.Unix() + 1
+
+	tests := []struct {
+		name     string
+		search   SearchOptions
+		expected []string // Relative paths of the matches
+	}{
+		{name: "name", search: SearchOptions{Name: "MAIN"}, expected: []string{"src/app/main.go", "src/lib/main_test.go"}},
+		{name: "name matches directories", search: SearchOptions{Name: "photos"}, expected: []string{"photos", "src/photos"}},
+		{name: "glob on names", search: SearchOptions{Glob: "*.go"}, expected: []string{"src/app/main.go", "src/app/util/x.go", "src/lib/main_test.go"}},
+		{name: "glob on paths", search: SearchOptions{Glob: "src/*/main*.go"}, expected: []string{"src/app/main.go", "src/lib/main_test.go"}},
+		{name: "glob with a fixed prefix", search: SearchOptions{Glob: "src/app/**"}, expected: []string{"src/app", "src/app/main.go", "src/app/util", "src/app/util/x.go"}},
+		{name: "regex", search: SearchOptions{Regex: `^photos/\d+/`}, expected: []string{"photos/2024/a.JPG", "photos/2024/b.png"}},
+		{name: "ext", search: SearchOptions{Ext: []string{"jpg", ".png"}}, expected: []string{"IMG_001.jpg", "photos/2024/a.JPG", "photos/2024/b.png", "photos/old/c.jpg"}},
+		{name: "type dir", search: SearchOptions{Type: SearchDirs, Name: "o"}, expected: []string{"photos", "photos/old", "src/photos"}},
+		{name: "type file", search: SearchOptions{Type: SearchFiles, Name: "photos"}, expected: []string{}},
+		{name: "min size", search: SearchOptions{MinSize: 10}, expected: []string{"IMG_001.jpg", "photos/2024/b.png", "src/app/main.go", "src/app/util/x.go", "src/lib/main_test.go"}},
+		{name: "size range", search: SearchOptions{MinSize: 1, MaxSize: 5}, expected: []string{"notes.txt", "photos/2024/a.JPG"}},
+		{name: "size rejects directories", search: SearchOptions{Name: "photos", MaxSize: 100}, expected: []string{}},
+		{name: "ext rejects directories", search: SearchOptions{Name: "o", Ext: []string{""}}, expected: []string{}},
+		{name: "modified before", search: SearchOptions{ModifiedBefore: cutoff}, expected: []string{"notes.txt", "photos/old/c.jpg"}},
+		{name: "modified after", search: SearchOptions{ModifiedAfter: cutoff, Ext: []string{"jpg"}}, expected: []string{"IMG_001.jpg", "photos/2024/a.JPG"}},
+		{name: "every criterion", search: SearchOptions{Name: "a", Glob: "photos/**", Ext: []string{"jpg"}, MinSize: 1, Type: SearchFiles}, expected: []string{"photos/2024/a.JPG"}},
+	}
+	for _, tt := range tests {
+		for _, stream := range []bool{false, true} {
+			name := tt.name
+			if stream {
+				name += " streamed"
+			}
+			t.Run(name, func(t *testing.T) {
+				var mu sync.Mutex
+				var received []string
+				search := tt.search
+				result, err := GenerateFileTree(context.Background(), root, Options{
+					Mode:     ModeSearch,
+					PathMode: PathModeRelative,
+					Search:   &search,
+					Stream:   stream,
+					OnMatch: func(match *SearchMatch) {
+						mu.Lock()
+						received = append(received, match.Node.Path)
+						mu.Unlock()
+					},
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				sort.Strings(received)
+				if received == nil {
+					received = []string{}
+				}
+				if !reflect.DeepEqual(received, tt.expected) {
+					t.Errorf("OnMatch received %q, want %q", received, tt.expected)
+				}
+
+				if stream {
+					if result.Tree != nil {
+						t.Errorf("streamed search has a tree: %v", result.Tree)
+					}
+					return
+				}
+				matches := result.Tree.(*SearchResult)
+				paths := []string{}
+				for _, match := range matches.Matches {
+					paths = append(paths, match.Node.Path)
+				}
+				sort.Strings(paths)
+				if !reflect.DeepEqual(paths, tt.expected) || matches.Count != len(tt.expected) {
+					t.Errorf("matches %q, count %d, want %q", paths, matches.Count, tt.expected)
+				}
+			})
+		}
+	}
+}
+
+func TestSearchAncestors(t *testing.T) {
+	root := makeTree(t, map[string]string{"a/b/c.txt": "", "d.txt": ""})
+
+	result, err := GenerateFileTree(context.Background(), root, Options{
+		Mode:     ModeSearch,
+		PathMode: PathModeRelative,
+		Search:   &SearchOptions{Ext: []string{"txt"}, Ancestors: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string][]string{"a/b/c.txt": {".", "a", "a/b"}, "d.txt": {"."}}
+	matches := result.Tree.(*SearchResult).Matches
+	if len(matches) != len(expected) {
+		t.Fatalf("got %d matches, want %d", len(matches), len(expected))
+	}
+	for _, match := range matches {
+		var ancestors []string
+		for _, ancestor := range match.Ancestors {
+			if !ancestor.IsDir {
+				t.Errorf("%s: ancestor %s is not a directory", match.Node.Path, ancestor.Path)
+			}
+			ancestors = append(ancestors, ancestor.Path)
+		}
+		if !reflect.DeepEqual(ancestors, expected[match.Node.Path]) {
+			t.Errorf("%s: ancestors %q, want %q", match.Node.Path, ancestors, expected[match.Node.Path])
+		}
+	}
+}
+
+func TestSearchSkipsDirectories(t *testing.T) {
+	tests := []struct {
+		glob    string
+		walked  []string
+		skipped []string
+	}{
+		{glob: "*.go", walked: []string{"src", "src/app", "docs"}},
+		{glob: "src/app/*.go", walked: []string{"src", "src/app"}, skipped: []string{"docs", "src/app/util", "src/lib"}},
+		{glob: "src/app/**/*.go", walked: []string{"src", "src/app", "src/app/util"}, skipped: []string{"docs", "src/lib"}},
+		{glob: "*/app/*.go", walked: []string{"src", "docs", "src/app"}, skipped: []string{"src/app/util"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.glob, func(t *testing.T) {
+			s, err := newSearcher(&SearchOptions{Glob: tt.glob}, nil, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, dir := range tt.walked {
+				if !s.canContain(dir) {
+					t.Errorf("%s skipped", dir)
+				}
+			}
+			for _, dir := range tt.skipped {
+				if s.canContain(dir) {
+					t.Errorf("%s walked", dir)
+				}
+			}
+		})
+	}
+}
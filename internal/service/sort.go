@@ -0,0 +1,145 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Keys the children of a directory can be sorted by
+const (
+	// SortByName sorts by name in byte order
+	SortByName = "name"
+	// SortByNatural sorts by name ignoring case, comparing runs of digits as numbers
+	SortByNatural = "natural"
+	// SortBySize sorts by size in bytes
+	SortBySize = "size"
+	// SortByModified sorts by last modified time
+	SortByModified = "mtime"
+	// SortByType sorts by file type, then by name
+	SortByType = "type"
+)
+
+// Sort orders
+const (
+	SortAsc  = "asc"
+	SortDesc = "desc"
+)
+
+// Checks the sort options and fills in the defaults
+func (s *SortOptions) validate() error {
+	switch s.By {
+	case "":
+		s.By = SortByName
+	case SortByName, SortByNatural, SortBySize, SortByModified, SortByType:
+	default:
+		return fmt.Errorf("%w: unknown sort key %q", ErrInvalidOptions, s.By)
+	}
+
+	switch s.Order {
+	case "":
+		s.Order = SortAsc
+	case SortAsc, SortDesc:
+	default:
+		return fmt.Errorf("%w: unknown sort order %q", ErrInvalidOptions, s.Order)
+	}
+
+	return nil
+}
+
+// Reports whether node a is ordered before node b. Ties are broken by name and path
+// so that the order never depends on the traversal order.
+func (s *SortOptions) less(a, b *FileNode) bool {
+	if s.DirsFirst && a.IsDir != b.IsDir {
+		return a.IsDir
+	}
+
+	cmp := 0
+	switch s.By {
+	case SortByNatural:
+		cmp = naturalCompare(a.Name, b.Name)
+	case SortBySize:
+		cmp = compareInt64(a.Size, b.Size)
+	case SortByModified:
+		cmp = compareInt64(a.LastModified, b.LastModified)
+	case SortByType:
+		cmp = strings.Compare(a.FileType, b.FileType)
+	}
+	if cmp == 0 {
+		cmp = strings.Compare(a.Name, b.Name)
+	}
+	if cmp == 0 {
+		cmp = strings.Compare(a.Path, b.Path)
+	}
+	if s.Order == SortDesc {
+		return cmp > 0
+	}
+
+	return cmp < 0
+}
+
+// Sorts the nodes in place
+func (s *SortOptions) sortNodes(nodes []*FileNode) {
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return s.less(nodes[i], nodes[j])
+	})
+}
+
+// Sorts the children of every directory in the tree
+func (s *SortOptions) sortTree(node *FileNode) {
+	s.sortNodes(node.Children)
+	for _, child := range node.Children {
+		if child.IsDir {
+			s.sortTree(child)
+		}
+	}
+}
+
+// Compares two integers, returning -1, 0 or 1
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+
+	return 0
+}
+
+// Compares two names ignoring case, with runs of digits compared by their numeric value
+func naturalCompare(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	i, j := 0, 0
+	for i < len(ra) && j < len(rb) {
+		if unicode.IsDigit(ra[i]) && unicode.IsDigit(rb[j]) {
+			// Compare the numbers without their leading zeros, the longer one is bigger
+			si, sj := i, j
+			for i < len(ra) && unicode.IsDigit(ra[i]) {
+				i++
+			}
+			for j < len(rb) && unicode.IsDigit(rb[j]) {
+				j++
+			}
+			na := strings.TrimLeft(string(ra[si:i]), "0")
+			nb := strings.TrimLeft(string(rb[sj:j]), "0")
+			if len(na) != len(nb) {
+				return compareInt64(int64(len(na)), int64(len(nb)))
+			}
+			if cmp := strings.Compare(na, nb); cmp != 0 {
+				return cmp
+			}
+			continue
+		}
+
+		ca, cb := unicode.ToLower(ra[i]), unicode.ToLower(rb[j])
+		if ca != cb {
+			return compareInt64(int64(ca), int64(cb))
+		}
+		i++
+		j++
+	}
+
+	return compareInt64(int64(len(ra)-i), int64(len(rb)-j))
+}
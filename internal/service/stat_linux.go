@@ -0,0 +1,79 @@
+//go:build linux
+
+package service
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// Reads the birth time of the entry, and the extended metadata when full is set.
+// statx is used so the birth time is reported wherever the file system records it.
+func readStat(path string, info os.FileInfo, full bool) (created int64, meta *FileMeta) {
+	mask := uint32(unix.STATX_BTIME)
+	if full {
+		mask |= unix.STATX_BASIC_STATS
+	}
+
+	var stx unix.Statx_t
+	if err := unix.Statx(unix.AT_FDCWD, path, unix.AT_SYMLINK_NOFOLLOW, int(mask), &stx); err != nil {
+		// Kernels or file systems without statx still have the classic stat fields
+		if full {
+			meta = metaFromStat(info)
+		}
+		return 0, meta
+	}
+
+	if stx.Mask&unix.STATX_BTIME != 0 {
+		created = stx.Btime.Sec
+	}
+	if full {
+		meta = &FileMeta{
+			Mode:       info.Mode().String(),
+			AccessTime: stx.Atime.Sec,
+			ChangeTime: stx.Ctime.Sec,
+			Uid:        stx.Uid,
+			Gid:        stx.Gid,
+			User:       lookupUser(stx.Uid),
+			Group:      lookupGroup(stx.Gid),
+			Inode:      stx.Ino,
+			Links:      uint64(stx.Nlink),
+			Device:     unix.Mkdev(stx.Dev_major, stx.Dev_minor),
+		}
+	}
+
+	return created, meta
+}
+
+// Builds the extended metadata from the result of a classic stat call
+func metaFromStat(info os.FileInfo) *FileMeta {
+	meta := &FileMeta{Mode: info.Mode().String()}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return meta
+	}
+	meta.AccessTime = int64(st.Atim.Sec)
+	meta.ChangeTime = int64(st.Ctim.Sec)
+	meta.Uid = st.Uid
+	meta.Gid = st.Gid
+	meta.User = lookupUser(st.Uid)
+	meta.Group = lookupGroup(st.Gid)
+	meta.Inode = st.Ino
+	meta.Links = uint64(st.Nlink)
+	meta.Device = uint64(st.Dev)
+
+	return meta
+}
+
+// Returns a key identifying the file by its device and inode numbers
+func fileKey(info os.FileInfo) (string, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", false
+	}
+
+	return fmt.Sprintf("%d:%d", st.Dev, st.Ino), true
+}
@@ -0,0 +1,22 @@
+//go:build !linux
+
+package service
+
+import (
+	"os"
+)
+
+// Reads the birth time of the entry, and the extended metadata when full is set.
+// Only Linux reports birth times and ownership, other platforms get the permission bits.
+func readStat(path string, info os.FileInfo, full bool) (created int64, meta *FileMeta) {
+	if full {
+		meta = &FileMeta{Mode: info.Mode().String()}
+	}
+
+	return 0, meta
+}
+
+// Returns a key identifying the file, which is not available on this platform
+func fileKey(info os.FileInfo) (string, bool) {
+	return "", false
+}
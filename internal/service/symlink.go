@@ -0,0 +1,129 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+
+	"FileTree-API/internal/security"
+)
+
+// Symlink modes deciding how symbolic links are handled during the walk
+const (
+	// SymlinkLink reports links as entries of their own with their target
+	SymlinkLink = "link"
+	// SymlinkFollow lists links like their target, and walks linked directories
+	SymlinkFollow = "follow"
+	// SymlinkSkip leaves links out of the result
+	SymlinkSkip = "skip"
+)
+
+// ancestry is the chain of directories from the walked root down to the directory being listed,
+// only kept in the follow mode
+type ancestry struct {
+	key    string
+	parent *ancestry
+}
+
+// Reports whether the directory is in the chain
+func (a *ancestry) contains(key string) bool {
+	for ; a != nil; a = a.parent {
+		if a.key == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the chain of a directory about to be walked, from the chain of its parent
+func (w *walker) enter(parent *ancestry, path string, info os.FileInfo) *ancestry {
+	if w.opts.Symlinks != SymlinkFollow {
+		return nil
+	}
+
+	return &ancestry{key: dirKey(path, info), parent: parent}
+}
+
+// Resolves a link for the follow mode. It returns a nil info for links that must be reported
+// as plain links: broken links, links leaving the jail, links to hidden entries when they are
+// not listed, and links to one of the directories being walked, which would make the walk loop.
+// Other links to directories that are listed elsewhere are followed, so the result does not
+// depend on the order in which directories are walked.
+func (w *walker) followLink(fullPath string, ancestors *ancestry) (string, os.FileInfo) {
+	target, err := filepath.EvalSymlinks(fullPath)
+	if err != nil || !security.IsWithin(w.jail(), target) {
+		return "", nil
+	}
+	if !w.opts.IncludeHidden && w.hiddenTarget(target) {
+		return "", nil
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		return "", nil
+	}
+	if info.IsDir() && ancestors.contains(dirKey(target, info)) {
+		return "", nil
+	}
+	// Directories containing the link above the walked root are not in the chain, but would loop as well
+	if info.IsDir() {
+		dir, err := filepath.EvalSymlinks(filepath.Dir(fullPath))
+		if err != nil || security.IsWithin(target, dir) {
+			return "", nil
+		}
+	}
+
+	return target, info
+}
+
+// Returns a key identifying a directory, its device and inode numbers when the platform has them
+func dirKey(path string, info os.FileInfo) string {
+	if key, ok := fileKey(info); ok {
+		return key
+	}
+	// Otherwise the resolved path identifies the directory
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		return real
+	}
+
+	return path
+}
+
+// Reports whether the target of a link is hidden, or lies below a hidden directory. Targets inside
+// the walked root are checked from there, since it may itself have been requested inside a hidden
+// directory. Hidden entries are only listed with includeHidden, which the hidden policy of the
+// roots already refused for the roots that forbid them.
+func (w *walker) hiddenTarget(target string) bool {
+	base := w.jail()
+	if security.IsWithin(w.root, target) {
+		base = w.root
+	}
+
+	return security.HasHiddenPart(base, target)
+}
+
+// Returns the directory followed links must stay inside: the allowed root, or the walked root when no roots are configured
+func (w *walker) jail() string {
+	if w.opts.Scope.Base != "" {
+		return w.opts.Scope.Base
+	}
+
+	return w.root
+}
+
+// Returns the target of the link as shown in the output. Absolute targets would reveal the
+// server layout outside of the absolute path mode, so they are only shown inside the walked root.
+func (w *walker) linkTarget(fullPath string) string {
+	target, err := os.Readlink(fullPath)
+	if err != nil {
+		return ""
+	}
+	if w.opts.PathMode == PathModeAbsolute || !filepath.IsAbs(target) {
+		return target
+	}
+	target = filepath.Clean(target)
+	if security.IsWithin(w.root, target) {
+		return w.displayPath(target)
+	}
+
+	return ""
+}
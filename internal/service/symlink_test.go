@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Finds a node by its slash separated path below the tree, nil when it is not listed
+func findNode(tree *FileNode, rel string) *FileNode {
+	node := tree
+	for _, name := range strings.Split(rel, "/") {
+		var next *FileNode
+		for _, child := range node.Children {
+			if child.Name == name {
+				next = child
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		node = next
+	}
+
+	return node
+}
+
+func TestFollowLinks(t *testing.T) {
+	share := makeTree(t, map[string]string{
+		"walk/a.txt":        "hello",
+		"walk/sub/b.txt":    "",
+		"walk/.dot/c.txt":   "",
+		"other/o.txt":       "",
+		".secret/key.txt":   "",
+		"other/.h/h.txt":    "",
+		"other/nested/n.md": "",
+	})
+	outside := makeTree(t, map[string]string{"x.txt": ""})
+	links := map[string]string{
+		"walk/file":      "a.txt",
+		"walk/in":        "../other",
+		"walk/out":       outside,
+		"walk/outfile":   filepath.Join(outside, "x.txt"),
+		"walk/hidden":    "../.secret",
+		"walk/hiddenin":  "../other/.h",
+		"walk/dotlink":   ".dot",
+		"walk/broken":    "nowhere",
+		"walk/sub/up":    "..",
+		"walk/sub/self":  ".",
+		"walk/sub/share": "../..",
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, filepath.Join(share, filepath.FromSlash(link))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	walk := filepath.Join(share, "walk")
+
+	tests := []struct {
+		name     string
+		opts     Options
+		followed []string // Links listed like their target
+		plain    []string // Links reported as plain links
+	}{
+		{
+			name:     "jailed in the root",
+			opts:     Options{Symlinks: SymlinkFollow, Scope: Scope{Base: share}},
+			followed: []string{"file", "in"},
+			plain:    []string{"out", "outfile", "hidden", "hiddenin", "dotlink", "broken", "sub/up", "sub/self", "sub/share"},
+		},
+		{
+			name:     "jailed in the walked folder",
+			opts:     Options{Symlinks: SymlinkFollow},
+			followed: []string{"file"},
+			plain:    []string{"in", "out", "outfile", "hidden", "hiddenin", "dotlink", "broken", "sub/up", "sub/self", "sub/share"},
+		},
+		{
+			name:     "hidden entries",
+			opts:     Options{Symlinks: SymlinkFollow, Scope: Scope{Base: share}, IncludeHidden: true},
+			followed: []string{"file", "in", "hidden", "hiddenin", "dotlink"},
+			plain:    []string{"out", "outfile", "broken", "sub/up", "sub/self", "sub/share"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := GenerateFileTree(context.Background(), walk, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tree := result.Tree.(*FileNode)
+			for _, rel := range tt.followed {
+				node := findNode(tree, rel)
+				if node == nil {
+					t.Errorf("%s: not listed", rel)
+					continue
+				}
+				if !node.IsSymlink || !(node.IsDir && len(node.Children) > 0 || !node.IsDir && node.Size > 0) {
+					t.Errorf("%s: not followed: %+v", rel, node)
+				}
+			}
+			for _, rel := range tt.plain {
+				node := findNode(tree, rel)
+				if node == nil {
+					t.Errorf("%s: not listed", rel)
+					continue
+				}
+				if !node.IsSymlink || node.IsDir || len(node.Children) > 0 || node.Size != 0 {
+					t.Errorf("%s: followed, want a plain link: %+v", rel, node)
+				}
+			}
+		})
+	}
+}
@@ -6,7 +6,9 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/joho/godotenv"
@@ -47,6 +49,51 @@ func CheckEnvVariables(variables []string) {
 	}
 }
 
+// GetEnvInt reads a non-negative integer from the environment
+func GetEnvInt(name string, fallback int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		OutputMessage(nil, FatalOutput, 0, "Environment variable %s is not a valid number: %s", name, value)
+	}
+
+	return n
+}
+
+// GetEnvDuration reads a duration such as "30s" from the environment, plain numbers are seconds
+func GetEnvDuration(name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		OutputMessage(nil, FatalOutput, 0, "Environment variable %s is not a valid duration: %s", name, value)
+	}
+
+	return duration
+}
+
+// GetEnvBool reads a boolean such as "true" or "1" from the environment
+func GetEnvBool(name string, fallback bool) bool {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		OutputMessage(nil, FatalOutput, 0, "Environment variable %s is not a valid boolean: %s", name, value)
+	}
+
+	return b
+}
+
 // OutputMessage provides message output, output to HTTP or log according to mode
 func OutputMessage(w interface{}, mode MessageOutputMode, statusCode int, format string, a ...interface{}) {
 	message := fmt.Sprintf(format, a...)
@@ -88,16 +135,33 @@ func Base64UrlDecode(data string) ([]byte, error) {
 // Get the parameters separate by '::'
 func CheckOrganize(data string) (string, bool) {
 	s := strings.Split(data, "::")
-	if len(s) != 2 {
-		return s[0], false
-	}
-	if s[1] == "org" {
-		return s[0], true
+	for _, param := range s[1:] {
+		if param == "org" {
+			return s[0], true
+		}
 	}
 
 	return s[0], false
 }
 
+// Get the maximum depth from the 'depth=N' parameter, 0 means unlimited
+func CheckDepth(data string) int {
+	s := strings.Split(data, "::")
+	for _, param := range s[1:] {
+		value, found := strings.CutPrefix(param, "depth=")
+		if !found {
+			continue
+		}
+		depth, err := strconv.Atoi(value)
+		if err != nil || depth < 0 {
+			return 0
+		}
+		return depth
+	}
+
+	return 0
+}
+
 // Check if the request is WebSocket
 func IsWebSocket(r *http.Request) bool {
 	return websocket.IsWebSocketUpgrade(r)
@@ -0,0 +1,153 @@
+package api
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrInvalidCompactTree is returned when the columns of a compact tree do not fit together
+var ErrInvalidCompactTree = errors.New("invalid compact tree")
+
+// Bits of the CompactTree flags
+const (
+	FlagDir         = 1 << iota // The node is a directory
+	FlagSymlink                 // The node is a symbolic link
+	FlagHasChildren             // The directory has entries below the depth limit
+	FlagTruncated               // The directory was not listed completely
+	FlagCreated                 // The node has a known creation time
+)
+
+// CompactTree is a columnar encoding of a file tree. Node i is described by the i-th value of every
+// column, nodes are listed parents first starting with the root, and paths, key names and directory
+// totals are left out since Decode can rebuild them.
+type CompactTree struct {
+	Strings  []string `json:"strings"`  // String table shared by the name, fileType and mime columns
+	Root     string   `json:"root"`     // Path of the root node, the paths of the others are built from their names
+	Parent   []int    `json:"parent"`   // Index of the parent node, -1 for the root
+	Name     []int    `json:"name"`     // Index of the name in Strings
+	FileType []int    `json:"fileType"` // Index of the file type in Strings, -1 for none
+	Size     []int64  `json:"size"`     // Size of files, 0 for directories
+	Modified []int64  `json:"modified"` // Modification time, as the difference to the previous node
+	Created  []int64  `json:"created"`  // Creation time, as the difference to the modification time of the node
+	Flags    []int    `json:"flags"`
+
+	// Columns that are only present when at least one node has a value
+	Mime       []int          `json:"mime,omitempty"` // Index of the MIME type in Strings, -1 for none
+	Hash       []string       `json:"hash,omitempty"`
+	LinkTarget map[int]string `json:"linkTarget,omitempty"` // By node index
+	Error      map[int]string `json:"error,omitempty"`      // By node index
+}
+
+// FileNode is a node of a file tree rebuilt from a CompactTree
+type FileNode struct {
+	Name           string      `json:"name"`
+	Size           int64       `json:"size,omitempty"`
+	FileType       string      `json:"fileType,omitempty"`
+	Mime           string      `json:"mime,omitempty"`
+	Path           string      `json:"path"`
+	CreatedDate    int64       `json:"createdDate,omitempty"`
+	LastModified   int64       `json:"lastModified,omitempty"`
+	FileCount      int64       `json:"fileCount,omitempty"`
+	NewestModified int64       `json:"newestModified,omitempty"`
+	IsDir          bool        `json:"isDir"`
+	IsSymlink      bool        `json:"isSymlink,omitempty"`
+	LinkTarget     string      `json:"linkTarget,omitempty"`
+	HasChildren    bool        `json:"hasChildren,omitempty"`
+	Truncated      bool        `json:"truncated,omitempty"`
+	Error          string      `json:"error,omitempty"`
+	Hash           string      `json:"hash,omitempty"`
+	Children       []*FileNode `json:"children,omitempty"`
+}
+
+// Decode rebuilds the tree, with the paths and the recursive size, file count and
+// newest modification time of every directory
+func (t *CompactTree) Decode() (*FileNode, error) {
+	count := len(t.Parent)
+	if count == 0 || len(t.Name) != count || len(t.FileType) != count || len(t.Size) != count ||
+		len(t.Modified) != count || len(t.Created) != count || len(t.Flags) != count ||
+		t.Mime != nil && len(t.Mime) != count || t.Hash != nil && len(t.Hash) != count {
+		return nil, ErrInvalidCompactTree
+	}
+
+	nodes := make([]*FileNode, count)
+	var modified int64
+	for i := 0; i < count; i++ {
+		modified += t.Modified[i]
+		flags := t.Flags[i]
+		node := &FileNode{
+			Size:         t.Size[i],
+			LastModified: modified,
+			IsDir:        flags&FlagDir != 0,
+			IsSymlink:    flags&FlagSymlink != 0,
+			HasChildren:  flags&FlagHasChildren != 0,
+			Truncated:    flags&FlagTruncated != 0,
+			LinkTarget:   t.LinkTarget[i],
+			Error:        t.Error[i],
+		}
+		var ok bool
+		if node.Name, ok = t.lookup(t.Name[i]); !ok {
+			return nil, ErrInvalidCompactTree
+		}
+		if node.FileType, ok = t.lookup(t.FileType[i]); !ok {
+			return nil, ErrInvalidCompactTree
+		}
+		if t.Mime != nil {
+			if node.Mime, ok = t.lookup(t.Mime[i]); !ok {
+				return nil, ErrInvalidCompactTree
+			}
+		}
+		if t.Hash != nil {
+			node.Hash = t.Hash[i]
+		}
+		if flags&FlagCreated != 0 {
+			node.CreatedDate = modified + t.Created[i]
+		}
+
+		// Parents always come first, so their path is already known
+		parent := t.Parent[i]
+		switch {
+		case i == 0 && parent == -1:
+			node.Path = t.Root
+		case i > 0 && parent >= 0 && parent < i:
+			node.Path = joinPath(nodes[parent].Path, node.Name)
+			nodes[parent].Children = append(nodes[parent].Children, node)
+		default:
+			return nil, ErrInvalidCompactTree
+		}
+		nodes[i] = node
+	}
+
+	// Add up the directory totals from the deepest nodes to the root
+	for i := count - 1; i > 0; i-- {
+		node, parent := nodes[i], nodes[t.Parent[i]]
+		if !node.IsDir {
+			parent.FileCount++
+		}
+		parent.FileCount += node.FileCount
+		parent.Size += node.Size
+		parent.NewestModified = max(parent.NewestModified, node.NewestModified, node.LastModified)
+	}
+
+	return nodes[0], nil
+}
+
+// Returns the string at the index of the string table, -1 stands for the empty string
+func (t *CompactTree) lookup(index int) (string, bool) {
+	if index == -1 {
+		return "", true
+	}
+	if index < 0 || index >= len(t.Strings) {
+		return "", false
+	}
+
+	return t.Strings[index], true
+}
+
+// Builds the path of a node from the path of its parent, relative trees start at "."
+func joinPath(parent, name string) string {
+	if parent == "." {
+		return name
+	}
+
+	return strings.TrimSuffix(parent, "/") + "/" + name
+}